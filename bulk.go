@@ -0,0 +1,471 @@
+package gotiny
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"unsafe"
+)
+
+// bulkEng holds the functions that encode, decode, skip and size n consecutive
+// numbers of one kind starting at p. Slice and array engines of numeric kinds use
+// them instead of calling the element engine once per element. The encoding is the
+// same as that of the element engines, unless the encoder or decoder uses packed
+// floats.
+type bulkEng struct {
+	enc  func(e *Encoder, p unsafe.Pointer, n int)
+	dec  func(d *Decoder, p unsafe.Pointer, n int)
+	skip func(d *Decoder, n int)
+	size func(p unsafe.Pointer, n int) int
+}
+
+// bulkChunk is the number of elements encoded between two flushes of a streaming encoder.
+const bulkChunk = 1 << 10
+
+var (
+	// bulkEngines is an array of bulk engines indexed by reflect.Kind.
+	bulkEngines = [...]*bulkEng{
+		reflect.Int:        {encInts, decInts, skipUint64s, sizeInts},
+		reflect.Int8:       {encBytesN, decBytesN, skipBytesN, sizeBytesN},
+		reflect.Int16:      {encInt16s, decInt16s, skipUint16s, sizeInt16s},
+		reflect.Int32:      {encInt32s, decInt32s, skipUint32s, sizeInt32s},
+		reflect.Int64:      {encInt64s, decInt64s, skipUint64s, sizeInt64s},
+		reflect.Uint:       {encUints, decUints, skipUint64s, sizeUints},
+		reflect.Uint8:      {encBytesN, decBytesN, skipBytesN, sizeBytesN},
+		reflect.Uint16:     {encUint16s, decUint16s, skipUint16s, sizeUint16s},
+		reflect.Uint32:     {encUint32s, decUint32s, skipUint32s, sizeUint32s},
+		reflect.Uint64:     {encUint64s, decUint64s, skipUint64s, sizeUint64s},
+		reflect.Uintptr:    {encUintptrs, decUintptrs, skipUint64s, sizeUintptrs},
+		reflect.Float32:    {encFloat32s, decFloat32s, skipFloat32s, sizeFloat32s},
+		reflect.Float64:    {encFloat64s, decFloat64s, skipFloat64s, sizeFloat64s},
+		reflect.Complex64:  {encComplex64s, decComplex64s, skipComplex64s, sizeComplex64s},
+		reflect.Complex128: {encComplex128s, decComplex128s, skipComplex128s, sizeComplex128s},
+	}
+)
+
+func init() {
+	for _, rt := range []reflect.Type{
+		reflect.TypeFor[[]int](), reflect.TypeFor[[]int8](), reflect.TypeFor[[]int16](),
+		reflect.TypeFor[[]int32](), reflect.TypeFor[[]int64](), reflect.TypeFor[[]uint](),
+		reflect.TypeFor[[]uint16](), reflect.TypeFor[[]uint32](), reflect.TypeFor[[]uint64](),
+		reflect.TypeFor[[]uintptr](), reflect.TypeFor[[]float32](), reflect.TypeFor[[]float64](),
+		reflect.TypeFor[[]complex64](), reflect.TypeFor[[]complex128](),
+	} {
+		b, size := bulkEngines[rt.Elem().Kind()], rt.Elem().Size()
+		rt2encEng[rt] = bulkSliceEncEngine(b, size)
+		rt2decEng[rt] = bulkSliceDecEngine(rt, b)
+		rt2skipEng[rt] = bulkSliceSkipEngine(b)
+		rt2sizeEng[rt] = bulkSliceSizeEngine(b)
+	}
+}
+
+// getBulkEngine returns the bulk engine for elements of type et, or nil if there is none.
+func getBulkEngine(et reflect.Type) *bulkEng {
+	if et.Kind() == reflect.Bool || !isPlainKind(et) {
+		return nil
+	}
+	return bulkEngines[et.Kind()]
+}
+
+// encBulk encodes n elements of the given size with b, in chunks if e is a
+// streaming encoder, so that it can flush in between.
+func encBulk(e *Encoder, b *bulkEng, p unsafe.Pointer, n int, size uintptr) {
+	if e.w == nil {
+		b.enc(e, p, n)
+		return
+	}
+	for n > bulkChunk {
+		b.enc(e, p, bulkChunk)
+		e.checkFlush()
+		p, n = unsafe.Add(p, bulkChunk*size), n-bulkChunk
+	}
+	b.enc(e, p, n)
+	e.checkFlush()
+}
+
+func bulkSliceEncEngine(b *bulkEng, size uintptr) encEng {
+	return func(e *Encoder, p unsafe.Pointer) {
+		isNotNil := !isNil(p)
+		e.encIsNotNil(isNotNil)
+		if isNotNil {
+			header := (*sliceHeader)(p)
+			e.encLength(header.len)
+			encBulk(e, b, header.data, header.len, size)
+		}
+	}
+}
+
+func bulkSliceDecEngine(rt reflect.Type, b *bulkEng) decEng {
+	return func(d *Decoder, p unsafe.Pointer) {
+		header := (*sliceHeader)(p)
+		if d.decIsNotNil() {
+			l := d.decLength()
+			if isNil(p) || header.cap < l {
+				*header = sliceHeader{data: reflect.MakeSlice(rt, l, l).UnsafePointer(), len: l, cap: l}
+			} else {
+				header.len = l
+			}
+			b.dec(d, header.data, l)
+		} else if !isNil(p) {
+			*header = sliceHeader{data: nil, len: 0, cap: 0}
+		}
+	}
+}
+
+func bulkSliceSkipEngine(b *bulkEng) skipEng {
+	return func(d *Decoder) {
+		if d.decIsNotNil() {
+			b.skip(d, d.decLength())
+		}
+	}
+}
+
+func bulkSliceSizeEngine(b *bulkEng) sizeEng {
+	return func(s *sizer, p unsafe.Pointer) {
+		s.bools++
+		if !isNil(p) {
+			header := (*sliceHeader)(p)
+			s.n += lengthLen(header.len) + b.size(header.data, header.len)
+		}
+	}
+}
+
+func encInts(e *Encoder, p unsafe.Pointer, n int) {
+	for _, v := range unsafe.Slice((*int)(p), n) {
+		e.encUint64(int64ToUint64(int64(v)))
+	}
+}
+func encInt16s(e *Encoder, p unsafe.Pointer, n int) {
+	for _, v := range unsafe.Slice((*int16)(p), n) {
+		e.encUint16(int16ToUint16(v))
+	}
+}
+func encInt32s(e *Encoder, p unsafe.Pointer, n int) {
+	for _, v := range unsafe.Slice((*int32)(p), n) {
+		e.encUint32(int32ToUint32(v))
+	}
+}
+func encInt64s(e *Encoder, p unsafe.Pointer, n int) {
+	for _, v := range unsafe.Slice((*int64)(p), n) {
+		e.encUint64(int64ToUint64(v))
+	}
+}
+func encUints(e *Encoder, p unsafe.Pointer, n int) {
+	for _, v := range unsafe.Slice((*uint)(p), n) {
+		e.encUint64(uint64(v))
+	}
+}
+func encUint16s(e *Encoder, p unsafe.Pointer, n int) {
+	for _, v := range unsafe.Slice((*uint16)(p), n) {
+		e.encUint16(v)
+	}
+}
+func encUint32s(e *Encoder, p unsafe.Pointer, n int) {
+	for _, v := range unsafe.Slice((*uint32)(p), n) {
+		e.encUint32(v)
+	}
+}
+func encUint64s(e *Encoder, p unsafe.Pointer, n int) {
+	for _, v := range unsafe.Slice((*uint64)(p), n) {
+		e.encUint64(v)
+	}
+}
+func encUintptrs(e *Encoder, p unsafe.Pointer, n int) {
+	for _, v := range unsafe.Slice((*uintptr)(p), n) {
+		e.encUint64(uint64(v))
+	}
+}
+func encBytesN(e *Encoder, p unsafe.Pointer, n int) {
+	e.buf = append(e.buf, unsafe.Slice((*byte)(p), n)...)
+}
+func encFloat32s(e *Encoder, p unsafe.Pointer, n int) {
+	if e.packedFloats {
+		encPackedFloat32s(e, p, n)
+		return
+	}
+	s := unsafe.Slice((*float32)(p), n)
+	for i := range s {
+		e.encUint32(float32ToUint32(s[i]))
+	}
+}
+func encFloat64s(e *Encoder, p unsafe.Pointer, n int) {
+	if e.packedFloats {
+		encPackedFloat64s(e, p, n)
+		return
+	}
+	s := unsafe.Slice((*float64)(p), n)
+	for i := range s {
+		e.encUint64(float64ToUint64(s[i]))
+	}
+}
+func encComplex64s(e *Encoder, p unsafe.Pointer, n int) {
+	if e.packedFloats {
+		encPackedFloat32s(e, p, 2*n)
+		return
+	}
+	for _, v := range unsafe.Slice((*complex64)(p), n) {
+		e.encUint64(complex64ToUint64(v))
+	}
+}
+func encComplex128s(e *Encoder, p unsafe.Pointer, n int) {
+	if e.packedFloats {
+		encPackedFloat64s(e, p, 2*n)
+		return
+	}
+	for _, v := range unsafe.Slice((*complex128)(p), n) {
+		e.encUint64(math.Float64bits(real(v)))
+		e.encUint64(math.Float64bits(imag(v)))
+	}
+}
+func encPackedFloat32s(e *Encoder, p unsafe.Pointer, n int) {
+	for _, v := range unsafe.Slice((*float32)(p), n) {
+		e.buf = binary.LittleEndian.AppendUint32(e.buf, math.Float32bits(v))
+	}
+}
+func encPackedFloat64s(e *Encoder, p unsafe.Pointer, n int) {
+	for _, v := range unsafe.Slice((*float64)(p), n) {
+		e.buf = binary.LittleEndian.AppendUint64(e.buf, math.Float64bits(v))
+	}
+}
+
+func decInts(d *Decoder, p unsafe.Pointer, n int) {
+	s := unsafe.Slice((*int)(p), n)
+	for i := range s {
+		s[i] = d.decInt()
+	}
+}
+func decInt16s(d *Decoder, p unsafe.Pointer, n int) {
+	s := unsafe.Slice((*int16)(p), n)
+	for i := range s {
+		s[i] = uint16ToInt16(d.decUint16())
+	}
+}
+func decInt32s(d *Decoder, p unsafe.Pointer, n int) {
+	s := unsafe.Slice((*int32)(p), n)
+	for i := range s {
+		s[i] = uint32ToInt32(d.decUint32())
+	}
+}
+func decInt64s(d *Decoder, p unsafe.Pointer, n int) {
+	s := unsafe.Slice((*int64)(p), n)
+	for i := range s {
+		s[i] = uint64ToInt64(d.decUint64())
+	}
+}
+func decUints(d *Decoder, p unsafe.Pointer, n int) {
+	s := unsafe.Slice((*uint)(p), n)
+	for i := range s {
+		s[i] = d.decUint()
+	}
+}
+func decUint16s(d *Decoder, p unsafe.Pointer, n int) {
+	s := unsafe.Slice((*uint16)(p), n)
+	for i := range s {
+		s[i] = d.decUint16()
+	}
+}
+func decUint32s(d *Decoder, p unsafe.Pointer, n int) {
+	s := unsafe.Slice((*uint32)(p), n)
+	for i := range s {
+		s[i] = d.decUint32()
+	}
+}
+func decUint64s(d *Decoder, p unsafe.Pointer, n int) {
+	s := unsafe.Slice((*uint64)(p), n)
+	for i := range s {
+		s[i] = d.decUint64()
+	}
+}
+func decUintptrs(d *Decoder, p unsafe.Pointer, n int) {
+	s := unsafe.Slice((*uintptr)(p), n)
+	for i := range s {
+		s[i] = d.decUintptr()
+	}
+}
+func decBytesN(d *Decoder, p unsafe.Pointer, n int) {
+	d.index += copy(unsafe.Slice((*byte)(p), n), d.buf[d.index:d.index+n])
+}
+func decFloat32s(d *Decoder, p unsafe.Pointer, n int) {
+	if d.packedFloats {
+		decPackedFloat32s(d, p, n)
+		return
+	}
+	s := unsafe.Slice((*float32)(p), n)
+	for i := range s {
+		s[i] = uint32ToFloat32(d.decUint32())
+	}
+}
+func decFloat64s(d *Decoder, p unsafe.Pointer, n int) {
+	if d.packedFloats {
+		decPackedFloat64s(d, p, n)
+		return
+	}
+	s := unsafe.Slice((*float64)(p), n)
+	for i := range s {
+		s[i] = uint64ToFloat64(d.decUint64())
+	}
+}
+func decComplex64s(d *Decoder, p unsafe.Pointer, n int) {
+	if d.packedFloats {
+		decPackedFloat32s(d, p, 2*n)
+		return
+	}
+	s := unsafe.Slice((*complex64)(p), n)
+	for i := range s {
+		s[i] = uint64ToComplex64(d.decUint64())
+	}
+}
+func decComplex128s(d *Decoder, p unsafe.Pointer, n int) {
+	if d.packedFloats {
+		decPackedFloat64s(d, p, 2*n)
+		return
+	}
+	s := unsafe.Slice((*complex128)(p), n)
+	for i := range s {
+		re := math.Float64frombits(d.decUint64())
+		s[i] = complex(re, math.Float64frombits(d.decUint64()))
+	}
+}
+func decPackedFloat32s(d *Decoder, p unsafe.Pointer, n int) {
+	buf := d.buf[d.index : d.index+4*n]
+	d.index += 4 * n
+	s := unsafe.Slice((*float32)(p), n)
+	for i := range s {
+		s[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+}
+func decPackedFloat64s(d *Decoder, p unsafe.Pointer, n int) {
+	buf := d.buf[d.index : d.index+8*n]
+	d.index += 8 * n
+	s := unsafe.Slice((*float64)(p), n)
+	for i := range s {
+		s[i] = math.Float64frombits(binary.LittleEndian.Uint64(buf[8*i:]))
+	}
+}
+
+func skipUint16s(d *Decoder, n int) {
+	for i := 0; i < n; i++ {
+		d.decUint16()
+	}
+}
+func skipUint32s(d *Decoder, n int) {
+	for i := 0; i < n; i++ {
+		d.decUint32()
+	}
+}
+func skipUint64s(d *Decoder, n int) {
+	for i := 0; i < n; i++ {
+		d.decUint64()
+	}
+}
+func skipBytesN(d *Decoder, n int) { d.index += n }
+func skipFloat32s(d *Decoder, n int) {
+	if d.packedFloats {
+		d.index += 4 * n
+		return
+	}
+	skipUint32s(d, n)
+}
+func skipFloat64s(d *Decoder, n int) {
+	if d.packedFloats {
+		d.index += 8 * n
+		return
+	}
+	skipUint64s(d, n)
+}
+func skipComplex64s(d *Decoder, n int) {
+	if d.packedFloats {
+		d.index += 8 * n
+		return
+	}
+	skipUint64s(d, n)
+}
+func skipComplex128s(d *Decoder, n int) {
+	if d.packedFloats {
+		d.index += 16 * n
+		return
+	}
+	skipUint64s(d, 2*n)
+}
+
+func sizeInts(p unsafe.Pointer, n int) (size int) {
+	for _, v := range unsafe.Slice((*int)(p), n) {
+		size += uint64Len(int64ToUint64(int64(v)))
+	}
+	return
+}
+func sizeInt16s(p unsafe.Pointer, n int) (size int) {
+	for _, v := range unsafe.Slice((*int16)(p), n) {
+		size += uint16Len(int16ToUint16(v))
+	}
+	return
+}
+func sizeInt32s(p unsafe.Pointer, n int) (size int) {
+	for _, v := range unsafe.Slice((*int32)(p), n) {
+		size += uint32Len(int32ToUint32(v))
+	}
+	return
+}
+func sizeInt64s(p unsafe.Pointer, n int) (size int) {
+	for _, v := range unsafe.Slice((*int64)(p), n) {
+		size += uint64Len(int64ToUint64(v))
+	}
+	return
+}
+func sizeUints(p unsafe.Pointer, n int) (size int) {
+	for _, v := range unsafe.Slice((*uint)(p), n) {
+		size += uint64Len(uint64(v))
+	}
+	return
+}
+func sizeUint16s(p unsafe.Pointer, n int) (size int) {
+	for _, v := range unsafe.Slice((*uint16)(p), n) {
+		size += uint16Len(v)
+	}
+	return
+}
+func sizeUint32s(p unsafe.Pointer, n int) (size int) {
+	for _, v := range unsafe.Slice((*uint32)(p), n) {
+		size += uint32Len(v)
+	}
+	return
+}
+func sizeUint64s(p unsafe.Pointer, n int) (size int) {
+	for _, v := range unsafe.Slice((*uint64)(p), n) {
+		size += uint64Len(v)
+	}
+	return
+}
+func sizeUintptrs(p unsafe.Pointer, n int) (size int) {
+	for _, v := range unsafe.Slice((*uintptr)(p), n) {
+		size += uint64Len(uint64(v))
+	}
+	return
+}
+func sizeBytesN(_ unsafe.Pointer, n int) int { return n }
+func sizeFloat32s(p unsafe.Pointer, n int) (size int) {
+	s := unsafe.Slice((*float32)(p), n)
+	for i := range s {
+		size += uint32Len(float32ToUint32(s[i]))
+	}
+	return
+}
+func sizeFloat64s(p unsafe.Pointer, n int) (size int) {
+	s := unsafe.Slice((*float64)(p), n)
+	for i := range s {
+		size += uint64Len(float64ToUint64(s[i]))
+	}
+	return
+}
+func sizeComplex64s(p unsafe.Pointer, n int) (size int) {
+	for _, v := range unsafe.Slice((*complex64)(p), n) {
+		size += uint64Len(complex64ToUint64(v))
+	}
+	return
+}
+func sizeComplex128s(p unsafe.Pointer, n int) (size int) {
+	for _, v := range unsafe.Slice((*complex128)(p), n) {
+		size += uint64Len(math.Float64bits(real(v))) + uint64Len(math.Float64bits(imag(v)))
+	}
+	return
+}
@@ -0,0 +1,147 @@
+package gotiny
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+	"unsafe"
+)
+
+type celsius float64
+
+var bulkValues = []any{
+	&[]int{0, 1, -1, math.MaxInt, math.MinInt},
+	&[]int8{0, -1, math.MaxInt8, math.MinInt8},
+	&[]int16{0, -1, math.MaxInt16, math.MinInt16},
+	&[]int32{0, -1, 1 << 20, math.MaxInt32, math.MinInt32},
+	&[]int64{0, -1, 1 << 40, math.MaxInt64, math.MinInt64},
+	&[]uint{0, 1, math.MaxUint},
+	&[]uint16{0, 1 << 7, math.MaxUint16},
+	&[]uint32{0, 1 << 14, math.MaxUint32},
+	&[]uint64{0, 1 << 56, math.MaxUint64},
+	&[]uintptr{0, 1, 1 << 31},
+	&[]float32{0, -1.5, math.MaxFloat32, float32(math.Inf(1))},
+	&[]float64{0, math.Pi, -math.MaxFloat64, math.NaN()},
+	&[]complex64{0, complex(1, -2), complex(math.MaxFloat32, 0)},
+	&[]complex128{0, complex(math.Pi, math.E), complex(0, -math.MaxFloat64)},
+	&[]celsius{-273.15, 0, 100},
+	new([]int32),
+	&[]float64{},
+	&[16]byte{1, 2, 3, 255},
+	&[4]int64{-1, 0, 1, math.MaxInt64},
+	&[3]celsius{1, 2, 3},
+	&[2]complex128{complex(1, 2), complex(3, 4)},
+}
+
+// encElements encodes the slice or array p points to with its element engine,
+// the way the generic slice and array engines do.
+func encElements(p any) []byte {
+	v := reflect.ValueOf(p).Elem()
+	e := Encoder{}
+	if v.Kind() == reflect.Slice {
+		e.encIsNotNil(!v.IsNil())
+		if v.IsNil() {
+			return e.buf
+		}
+		e.encLength(v.Len())
+	}
+	eEng := encEngines[v.Type().Elem().Kind()]
+	for i := 0; i < v.Len(); i++ {
+		eEng(&e, unsafe.Pointer(v.Index(i).UnsafeAddr()))
+	}
+	return e.buf
+}
+
+func TestBulk(t *testing.T) {
+	for _, p := range bulkValues {
+		buf := Marshal(p)
+		if want := encElements(p); !bytes.Equal(buf, want) {
+			t.Fatalf("%T: encoded %x, want %x", p, buf, want)
+		}
+		r := reflect.New(reflect.TypeOf(p).Elem())
+		if n := Unmarshal(buf, r.Interface()); n != len(buf) {
+			t.Fatalf("%T: decoded %d of %d bytes", p, n, len(buf))
+		}
+		if !bytes.Equal(Marshal(r.Interface()), buf) {
+			t.Fatalf("%T: decoded %v, want %v", p, r.Elem(), reflect.ValueOf(p).Elem())
+		}
+		if Size(p) != len(buf) {
+			t.Fatalf("%T: Size %d, encoded %d bytes", p, Size(p), len(buf))
+		}
+		var d Decoder
+		if n := d.Skip(buf, r.Type().Elem()); n != len(buf) {
+			t.Fatalf("%T: skipped %d of %d bytes", p, n, len(buf))
+		}
+	}
+}
+
+func TestPackedFloats(t *testing.T) {
+	v := []float64{math.Pi, -0.5}
+	e := NewEncoderWithPtr(&v)
+	e.SetPackedFloats(true)
+	buf := bytes.Clone(e.Encode(&v))
+	want := binary.LittleEndian.AppendUint64([]byte{1, 2}, math.Float64bits(math.Pi))
+	want = binary.LittleEndian.AppendUint64(want, math.Float64bits(-0.5))
+	if !bytes.Equal(buf, want) {
+		t.Fatalf("encoded %x, want %x", buf, want)
+	}
+	if bytes.Equal(Marshal(&v), buf) {
+		t.Fatal("Marshal uses packed floats")
+	}
+	for _, p := range bulkValues {
+		e := NewEncoderWithPtr(p)
+		e.SetPackedFloats(true)
+		buf := bytes.Clone(e.Encode(p))
+		r := reflect.New(reflect.TypeOf(p).Elem())
+		d := NewDecoderWithPtr(r.Interface())
+		d.SetPackedFloats(true)
+		if n := d.Decode(buf, r.Interface()); n != len(buf) {
+			t.Fatalf("%T: decoded %d of %d bytes", p, n, len(buf))
+		}
+		if !bytes.Equal(e.Encode(r.Interface()), buf) {
+			t.Fatalf("%T: decoded %v, want %v", p, r.Elem(), reflect.ValueOf(p).Elem())
+		}
+		var sd Decoder
+		sd.SetPackedFloats(true)
+		if n := sd.Skip(buf, r.Type().Elem()); n != len(buf) {
+			t.Fatalf("%T: skipped %d of %d bytes", p, n, len(buf))
+		}
+	}
+
+	// a Raw holds what Marshal writes, whatever the setting of the encoder
+	type withRaw struct {
+		F []float64
+		R Raw[[]float64]
+	}
+	src := withRaw{F: v}
+	src.R.Set(v)
+	e = NewEncoderWithPtr(&src)
+	e.SetPackedFloats(true)
+	buf = bytes.Clone(e.Encode(&src))
+	var dst withRaw
+	d := NewDecoderWithPtr(&dst)
+	d.SetPackedFloats(true)
+	if n := d.Decode(buf, &dst); n != len(buf) || !reflect.DeepEqual(dst.F, v) {
+		t.Fatalf("decoded %d of %d bytes: %v", n, len(buf), dst.F)
+	}
+	if r, err := dst.R.Get(); err != nil || !reflect.DeepEqual(r, v) || !bytes.Equal(dst.R.Bytes(), Marshal(&v)) {
+		t.Fatalf("Raw holds %x: %v, %v", dst.R.Bytes(), r, err)
+	}
+
+	// envelopes record the setting
+	env := MarshalEnvelope(EnvelopeOptions{PackedFloats: true}, &v)
+	if h, err := ReadHeader(env); err != nil || !h.PackedFloats || !bytes.Equal(env[h.Len:], want) {
+		t.Fatalf("envelope %x: header %+v, %v", env, h, err)
+	}
+	for i := 0; i < 2; i++ { // the second time with a decoder from the pool
+		var r []float64
+		if _, err := UnmarshalEnvelope(env, &r); err != nil || !reflect.DeepEqual(r, v) {
+			t.Fatalf("decoded %v, %v", r, err)
+		}
+		if Unmarshal(Marshal(&v), &r); !reflect.DeepEqual(r, v) {
+			t.Fatalf("Unmarshal after an envelope with packed floats decoded %v", r)
+		}
+	}
+}
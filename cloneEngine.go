@@ -0,0 +1,231 @@
+package gotiny
+
+import (
+	"reflect"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+type cloneEng func(dst, src unsafe.Pointer) // 复制器
+
+var (
+	// rt2cloneEng is a map that associates Go types with their corresponding clone functions.
+	// Types that are built from other types get their clone functions built by buildCloneEngine.
+	rt2cloneEng = map[reflect.Type]cloneEng{
+		reflect.TypeFor[[]byte]():    cloneBytes,
+		reflect.TypeFor[time.Time](): cloneValue[time.Time],
+		reflect.TypeFor[struct{}]():  cloneIgnore,
+		reflect.TypeOf(nil):          cloneIgnore,
+	}
+
+	// cloneEngines is an array of cloneEng functions indexed by reflect.Kind.
+	// Values of these kinds hold no references that need to be copied, so they are copied by assignment.
+	cloneEngines = [...]cloneEng{
+		reflect.Bool:       cloneValue[bool],
+		reflect.Int:        cloneValue[int],
+		reflect.Int8:       cloneValue[int8],
+		reflect.Int16:      cloneValue[int16],
+		reflect.Int32:      cloneValue[int32],
+		reflect.Int64:      cloneValue[int64],
+		reflect.Uint:       cloneValue[uint],
+		reflect.Uint8:      cloneValue[uint8],
+		reflect.Uint16:     cloneValue[uint16],
+		reflect.Uint32:     cloneValue[uint32],
+		reflect.Uint64:     cloneValue[uint64],
+		reflect.Uintptr:    cloneValue[uintptr],
+		reflect.Float32:    cloneValue[float32],
+		reflect.Float64:    cloneValue[float64],
+		reflect.Complex64:  cloneValue[complex64],
+		reflect.Complex128: cloneValue[complex128],
+		reflect.String:     cloneValue[string],
+	}
+
+	cloneLock sync.Mutex
+	cloneSnap snapshot[reflect.Type, cloneEng]
+)
+
+/*
+Clone makes a deep copy of *src into *dst. The copy shares no memory with the
+source: pointers, slices, maps and interfaces are copied recursively, and
+[]byte values get their own backing array. Which fields are copied follows
+the encoding rules, so Clone(&dst, &src) leaves dst in the same state as
+Unmarshal(Marshal(&src), &dst), except that dst never aliases a buffer.
+Fields tagged with `gotiny:"-"` are left untouched.
+*/
+func Clone[T any](dst, src *T) {
+	getCloneEngine(reflect.TypeFor[T]())(unsafe.Pointer(dst), unsafe.Pointer(src))
+}
+
+func cloneIgnore(dst, src unsafe.Pointer) {}
+
+func cloneValue[T any](dst, src unsafe.Pointer) { *(*T)(dst) = *(*T)(src) }
+
+func cloneBytes(dst, src unsafe.Pointer) {
+	if isNil(src) {
+		*(*[]byte)(dst) = nil
+		return
+	}
+	*(*[]byte)(dst) = append([]byte{}, *(*[]byte)(src)...)
+}
+
+// getCloneEngine retrieves or builds a clone engine for the given reflect.Type,
+// in the same way getEncEngine does for encoding engines.
+func getCloneEngine(rt reflect.Type) cloneEng {
+	if engine := cloneSnap.load()[rt]; engine != nil {
+		return engine
+	}
+	cloneLock.Lock()
+	defer cloneLock.Unlock()
+	var engine cloneEng
+	buildCloneEngine(rt, &engine)
+	cloneSnap.store(rt2cloneEng)
+	return engine
+}
+
+// isPlainKind reports whether values of rt can be copied with a plain memory copy
+// and are encoded by the engine of their kind.
+func isPlainKind(rt reflect.Type) bool {
+	kind := rt.Kind()
+	if kind < reflect.Bool || kind > reflect.Complex128 {
+		return false
+	}
+	enc, _ := implementOtherSerializer(rt)
+	return enc == nil
+}
+
+// memCopy copies n bytes from src to dst.
+func memCopy(dst, src unsafe.Pointer, n int) {
+	copy(unsafe.Slice((*byte)(dst), n), unsafe.Slice((*byte)(src), n))
+}
+
+// buildCloneEngine constructs a clone engine for the given reflect.Type and assigns it to engPtr.
+// It mirrors buildEncEngine: types implementing one of the serializer interfaces are copied
+// by encoding and decoding them, and composite types get engines that walk the source and
+// destination memory in parallel, building the engines of their element types in deferred calls.
+func buildCloneEngine(rt reflect.Type, engPtr *cloneEng) {
+	engine := rt2cloneEng[rt]
+	if engine != nil {
+		*engPtr = engine
+		return
+	}
+
+	if enc, dec := implementOtherSerializer(rt); enc != nil {
+		engine = func(dst, src unsafe.Pointer) {
+			e := Encoder{}
+			enc(&e, src)
+			dec(&Decoder{buf: e.buf}, dst)
+		}
+		rt2cloneEng[rt] = engine
+		*engPtr = engine
+		return
+	}
+
+	kind := rt.Kind()
+	var eEng cloneEng
+	switch kind {
+	case reflect.Ptr:
+		et := rt.Elem()
+		defer buildCloneEngine(et, &eEng)
+		engine = func(dst, src unsafe.Pointer) {
+			if isNil(src) {
+				*(*unsafe.Pointer)(dst) = nil
+				return
+			}
+			ep := reflect.New(et).UnsafePointer()
+			eEng(ep, *(*unsafe.Pointer)(src))
+			*(*unsafe.Pointer)(dst) = ep
+		}
+	case reflect.Array:
+		et, l := rt.Elem(), rt.Len()
+		size := int(et.Size())
+		if isPlainKind(et) {
+			n := int(rt.Size())
+			engine = func(dst, src unsafe.Pointer) { memCopy(dst, src, n) }
+			break
+		}
+		defer buildCloneEngine(et, &eEng)
+		engine = func(dst, src unsafe.Pointer) {
+			for i := 0; i < l; i++ {
+				eEng(unsafe.Add(dst, i*size), unsafe.Add(src, i*size))
+			}
+		}
+	case reflect.Slice:
+		et := rt.Elem()
+		size := int(et.Size())
+		plain := isPlainKind(et)
+		if !plain {
+			defer buildCloneEngine(et, &eEng)
+		}
+		engine = func(dst, src unsafe.Pointer) {
+			if isNil(src) {
+				*(*sliceHeader)(dst) = sliceHeader{}
+				return
+			}
+			l := (*sliceHeader)(src).len
+			data, sData := reflect.MakeSlice(rt, l, l).UnsafePointer(), (*sliceHeader)(src).data
+			if plain {
+				memCopy(data, sData, l*size)
+			} else {
+				for i := 0; i < l; i++ {
+					eEng(unsafe.Add(data, i*size), unsafe.Add(sData, i*size))
+				}
+			}
+			*(*sliceHeader)(dst) = sliceHeader{data: data, len: l, cap: l}
+		}
+	case reflect.Map:
+		kt, vt := rt.Key(), rt.Elem()
+		var kEng cloneEng
+		defer buildCloneEngine(kt, &kEng)
+		defer buildCloneEngine(vt, &eEng)
+		engine = func(dst, src unsafe.Pointer) {
+			if isNil(src) {
+				*(*unsafe.Pointer)(dst) = nil
+				return
+			}
+			sv := reflect.NewAt(rt, src).Elem()
+			dv := reflect.MakeMapWithSize(rt, sv.Len())
+			key, val := reflect.New(kt).Elem(), reflect.New(vt).Elem()
+			iter := sv.MapRange()
+			for iter.Next() {
+				kEng(unsafe.Pointer(key.UnsafeAddr()), getUnsafePointer(iter.Key()))
+				eEng(unsafe.Pointer(val.UnsafeAddr()), getUnsafePointer(iter.Value()))
+				dv.SetMapIndex(key, val)
+			}
+			*(*unsafe.Pointer)(dst) = dv.UnsafePointer()
+		}
+	case reflect.Struct:
+		fields, offs := getFieldType(rt, 0)
+		nf := len(fields)
+		fEngines := make([]cloneEng, nf)
+		defer func() {
+			for i := 0; i < nf; i++ {
+				buildCloneEngine(fields[i], &fEngines[i])
+			}
+		}()
+		engine = func(dst, src unsafe.Pointer) {
+			for i := 0; i < len(fEngines) && i < len(offs); i++ {
+				fEngines[i](unsafe.Add(dst, offs[i]), unsafe.Add(src, offs[i]))
+			}
+		}
+	case reflect.Interface:
+		engine = func(dst, src unsafe.Pointer) {
+			sv, dv := reflect.NewAt(rt, src).Elem(), reflect.NewAt(rt, dst).Elem()
+			if sv.IsNil() {
+				dv.SetZero()
+				return
+			}
+			ev := sv.Elem()
+			et := ev.Type()
+			nv := reflect.New(et).Elem()
+			getCloneEngine(et)(unsafe.Pointer(nv.UnsafeAddr()), getUnsafePointer(ev))
+			dv.Set(nv)
+		}
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer, reflect.Invalid:
+		panic("not support " + rt.String() + " type")
+	default:
+		engine = cloneEngines[kind]
+	}
+	rt2cloneEng[rt] = engine
+	*engPtr = engine
+}
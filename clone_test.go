@@ -0,0 +1,50 @@
+package gotiny
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCloneValues(t *testing.T) {
+	for i, src := range srci {
+		dst := reflect.New(typs[i])
+		getCloneEngine(typs[i])(dst.UnsafePointer(), reflect.ValueOf(src).UnsafePointer())
+		Assert(t, nil, src, dst.Interface())
+	}
+}
+
+func TestCloneIndependent(t *testing.T) {
+	type typ struct {
+		B []byte
+		M map[string][]int
+		P *tA
+		I any
+	}
+	src := typ{
+		B: []byte("hello"),
+		M: map[string][]int{"a": {1, 2}},
+		P: &tA{Name: "a"},
+		I: []string{"x"},
+	}
+	var dst typ
+	Clone(&dst, &src)
+	Assert(t, nil, src, dst)
+
+	src.B[0] = 'j'
+	src.M["a"][0] = 9
+	src.P.Name = "b"
+	src.I.([]string)[0] = "y"
+	if string(dst.B) != "hello" || dst.M["a"][0] != 1 || dst.P.Name != "a" || dst.I.([]string)[0] != "x" {
+		t.Fatalf("clone shares memory with its source: %+v", dst)
+	}
+}
+
+func TestCloneBytesOwnership(t *testing.T) {
+	src := [][]byte{[]byte("abc"), nil, {}}
+	var dst [][]byte
+	Clone(&dst, &src)
+	Assert(t, nil, src, dst)
+	if &dst[0][0] == &src[0][0] {
+		t.Fatal("cloned []byte aliases its source")
+	}
+}
@@ -0,0 +1,127 @@
+/*
+Gotinydict builds a preset compression dictionary from sample encoded messages,
+with gotiny.BuildDictionary, for use with gotiny.RegisterDictionary.
+
+Usage:
+
+	gotinydict [-size n] [-o file] sample...
+
+Every sample argument is a file holding one message, typically the output of
+gotiny.Marshal for a value of the type the dictionary is meant for, or a
+directory whose files each hold one. The dictionary is written to the file
+given by -o, or to standard output. A summary of how much the dictionary
+shrinks the samples compressed with compress/flate is printed to standard
+error.
+
+The dictionary is meant to be embedded in the programs that encode and decode
+the messages, for example with go:embed, and registered under the same ID in
+all of them.
+*/
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/niubaoshu/gotiny"
+)
+
+var (
+	size   = flag.Int("size", gotiny.MaxDictionarySize, "maximum size of the dictionary in bytes")
+	output = flag.String("o", "", "output file; default standard output")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: gotinydict [-size n] [-o file] sample...\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(flag.Args(), *size, *output, os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintf(os.Stderr, "gotinydict: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run builds a dictionary of at most size bytes from the samples in paths and
+// writes it to the file out, or to stdout if out is empty, and the summary to report.
+func run(paths []string, size int, out string, stdout, report io.Writer) error {
+	samples, err := readSamples(paths)
+	if err != nil {
+		return err
+	}
+	if len(samples) < 2 {
+		return fmt.Errorf("need at least 2 samples, got %d", len(samples))
+	}
+	dict := gotiny.BuildDictionary(samples, size)
+	if out == "" {
+		_, err = stdout.Write(dict)
+	} else {
+		err = os.WriteFile(out, dict, 0o644)
+	}
+	if err != nil {
+		return err
+	}
+	total, plain, withDict := 0, 0, 0
+	for _, s := range samples {
+		total += len(s)
+		plain += flateLen(s, nil)
+		withDict += flateLen(s, dict)
+	}
+	fmt.Fprintf(report, "%d samples, %d bytes: %d bytes compressed, %d bytes with the %d byte dictionary\n",
+		len(samples), total, plain, withDict, len(dict))
+	return nil
+}
+
+// readSamples reads the files in paths, and the files in the directories in paths.
+func readSamples(paths []string) ([][]byte, error) {
+	var samples [][]byte
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		files := []string{path}
+		if fi.IsDir() {
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				return nil, err
+			}
+			files = files[:0]
+			for _, entry := range entries {
+				if entry.Type().IsRegular() {
+					files = append(files, filepath.Join(path, entry.Name()))
+				}
+			}
+		}
+		for _, file := range files {
+			sample, err := os.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			samples = append(samples, sample)
+		}
+	}
+	return samples, nil
+}
+
+// flateLen returns the length of s compressed with compress/flate and the preset
+// dictionary dict.
+func flateLen(s, dict []byte) int {
+	var buf bytes.Buffer
+	w, _ := flate.NewWriterDict(&buf, flate.BestCompression, dict)
+	w.Write(s)
+	w.Close()
+	return buf.Len()
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/niubaoshu/gotiny"
+)
+
+type message struct {
+	Method  string
+	Tenant  string
+	ID      uint64
+	Headers map[string]string
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 20; i++ {
+		m := message{Method: "orders.v1.Orders/Get", Tenant: "tenant-" + strings.Repeat("a", i%3), ID: uint64(i * 977),
+			Headers: map[string]string{"content-type": "application/gotiny"}}
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprint(i)), gotiny.Marshal(&m), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out := filepath.Join(t.TempDir(), "dict")
+	var report bytes.Buffer
+	if err := run([]string{dir}, 512, out, nil, &report); err != nil {
+		t.Fatal(err)
+	}
+	dict, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dict) == 0 || len(dict) > 512 || !bytes.Contains(dict, []byte("orders.v1")) {
+		t.Errorf("dictionary %q", dict)
+	}
+	if !strings.HasPrefix(report.String(), "20 samples") {
+		t.Errorf("report %q", report.String())
+	}
+
+	var stdout bytes.Buffer
+	if err := run([]string{filepath.Join(dir, "0"), filepath.Join(dir, "1")}, 0, "", &stdout, &report); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.Len() == 0 {
+		t.Error("no dictionary written to stdout")
+	}
+	if err := run([]string{filepath.Join(dir, "0")}, 0, "", &stdout, &report); err == nil {
+		t.Error("no error for a single sample")
+	}
+}
@@ -0,0 +1,562 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"strings"
+)
+
+type kind uint8
+
+const (
+	basicKind      kind = iota // bool, numbers and string
+	timeKind                   // time.Time
+	ptrKind                    // *T
+	sliceKind                  // []T
+	arrayKind                  // [N]T
+	mapKind                    // map[K]V
+	structKind                 // struct{...}
+	namedKind                  // named type encoded by a generated helper
+	nestedKind                 // named type with GotinyEncodeNested and GotinyDecodeNested methods
+	serializerKind             // named type with GotinyEncode and GotinyDecode methods
+	binaryKind                 // named type with MarshalBinary and UnmarshalBinary methods
+	gobKind                    // named type with GobEncode and GobDecode methods
+)
+
+// typ describes how a type is encoded.
+type typ struct {
+	kind   kind
+	expr   string // the type in Go syntax, as used in the generated file
+	basic  string // the underlying basic type of basicKind types, with byte and rune resolved
+	elem   *typ   // element type of pointers, slices, arrays and maps
+	key    *typ   // key type of maps
+	fields []field
+}
+
+type field struct {
+	name string // "_" for blank fields
+	t    *typ
+}
+
+// generator writes the methods of the requested types and the helpers they need.
+type generator struct {
+	pkg      *pkgInfo
+	gen      map[string]bool // requested types
+	buf      bytes.Buffer
+	queue    []string        // named types whose helpers still have to be written
+	queued   map[string]bool // named types whose helpers have been queued
+	usesTime bool
+	n        int // counter for the names of local variables
+}
+
+// generate returns the source of the file with the methods of the types names of pkg.
+func generate(pkg *pkgInfo, names []string) ([]byte, error) {
+	g := &generator{pkg: pkg, gen: map[string]bool{}, queued: map[string]bool{}}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		spec := pkg.types[name]
+		if spec == nil {
+			return nil, fmt.Errorf("type %s not found in package %s", name, pkg.name)
+		}
+		if spec.Assign.IsValid() || spec.TypeParams != nil {
+			return nil, fmt.Errorf("%s: aliases and generic types are not supported", name)
+		}
+		g.gen[name] = true
+	}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		fmt.Fprintf(&g.buf, `
+// GotinyEncode appends the gotiny encoding of v to buf.
+func (v *%[1]s) GotinyEncode(buf []byte) []byte {
+	e := gotinyEncoder{buf: buf}
+	e.encode%[1]s(v)
+	return e.buf
+}
+
+// GotinyDecode decodes buf into v and returns the number of bytes read.
+func (v *%[1]s) GotinyDecode(buf []byte) int {
+	d := gotinyDecoder{buf: buf}
+	d.decode%[1]s(v)
+	return d.index
+}
+
+// GotinyEncodeNested appends the gotiny encoding of v to buf as part of an
+// enclosing value, whose byte of bools is at buf[boolPos] if boolBit is not 0.
+// It returns the extended buffer and the new state of the bools.
+func (v *%[1]s) GotinyEncodeNested(buf []byte, boolPos int, boolBit byte) ([]byte, int, byte) {
+	e := gotinyEncoder{buf: buf, boolPos: boolPos, boolBit: boolBit}
+	e.encode%[1]s(v)
+	return e.buf, e.boolPos, e.boolBit
+}
+
+// GotinyDecodeNested decodes buf[index:] into v as part of an enclosing value,
+// whose byte of bools is boolByte if boolBit is not 0. It returns the index
+// of the next byte to read and the new state of the bools.
+func (v *%[1]s) GotinyDecodeNested(buf []byte, index int, boolByte, boolBit byte) (int, byte, byte) {
+	d := gotinyDecoder{buf: buf, index: index, boolPos: boolByte, boolBit: boolBit}
+	d.decode%[1]s(v)
+	return d.index, d.boolPos, d.boolBit
+}
+`, name)
+		g.enqueue(name)
+	}
+	for len(g.queue) > 0 {
+		name := g.queue[0]
+		g.queue = g.queue[1:]
+		if err := g.helpers(name); err != nil {
+			return nil, err
+		}
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by gotinygen; DO NOT EDIT.\n\npackage %s\n\nimport (\n\t\"math\"\n\t\"math/bits\"\n", pkg.name)
+	if g.usesTime {
+		src.WriteString("\t\"time\"\n")
+	}
+	src.WriteString(")\n")
+	src.WriteString(runtime)
+	src.Write(g.buf.Bytes())
+	return src.Bytes(), nil
+}
+
+func (g *generator) enqueue(name string) {
+	if !g.queued[name] {
+		g.queued[name] = true
+		g.queue = append(g.queue, name)
+	}
+}
+
+// helpers writes the functions that encode and decode the underlying type of
+// the named type name.
+func (g *generator) helpers(name string) error {
+	if g.pkg.podTag[name] {
+		return fmt.Errorf("%s: plain-old-data types are not supported", name)
+	}
+	spec := g.pkg.types[name]
+	expr, imports := spec.Type, g.pkg.imports[name]
+	// the underlying type of a type defined by another named type is that of the latter
+	for {
+		id, ok := expr.(*ast.Ident)
+		if !ok || g.pkg.types[id.Name] == nil {
+			break
+		}
+		spec := g.pkg.types[id.Name]
+		if spec.TypeParams != nil {
+			return fmt.Errorf("%s: generic types are not supported", id.Name)
+		}
+		expr, imports = spec.Type, g.pkg.imports[id.Name]
+	}
+	t, err := g.resolve(expr, imports)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	switch t.kind {
+	case basicKind:
+		t.expr = name
+	case timeKind:
+		return fmt.Errorf("%s: types defined from time.Time are not supported", name)
+	}
+	v := deref("v", t)
+	fmt.Fprintf(&g.buf, "\nfunc (e *gotinyEncoder) encode%s(v *%[1]s) {\n%s}\n", name, g.enc(v, t))
+	fmt.Fprintf(&g.buf, "\nfunc (d *gotinyDecoder) decode%s(v *%[1]s) {\n%s}\n", name, g.dec(v, t))
+	return nil
+}
+
+// resolve describes the type expr, written in a file with the given imports.
+func (g *generator) resolve(expr ast.Expr, imports map[string]string) (*typ, error) {
+	switch expr := expr.(type) {
+	case *ast.ParenExpr:
+		return g.resolve(expr.X, imports)
+	case *ast.Ident:
+		if spec := g.pkg.types[expr.Name]; spec != nil {
+			return g.resolveNamed(expr.Name)
+		}
+		switch expr.Name {
+		case "bool", "string", "int", "int8", "int16", "int32", "int64",
+			"uint", "uint8", "uint16", "uint32", "uint64", "uintptr",
+			"float32", "float64", "complex64", "complex128":
+			return &typ{kind: basicKind, expr: expr.Name, basic: expr.Name}, nil
+		case "byte":
+			return &typ{kind: basicKind, expr: expr.Name, basic: "uint8"}, nil
+		case "rune":
+			return &typ{kind: basicKind, expr: expr.Name, basic: "int32"}, nil
+		}
+		return nil, fmt.Errorf("type %s is not supported", expr.Name)
+	case *ast.SelectorExpr:
+		if x, ok := expr.X.(*ast.Ident); ok && imports[x.Name] == "time" {
+			switch expr.Sel.Name {
+			case "Time":
+				g.usesTime = true
+				return &typ{kind: timeKind, expr: "time.Time"}, nil
+			case "Duration":
+				g.usesTime = true
+				return &typ{kind: basicKind, expr: "time.Duration", basic: "int64"}, nil
+			}
+		}
+		return nil, fmt.Errorf("type %s of another package is not supported", source(expr))
+	case *ast.StarExpr:
+		elem, err := g.resolve(expr.X, imports)
+		if err != nil {
+			return nil, err
+		}
+		return &typ{kind: ptrKind, expr: "*" + elem.expr, elem: elem}, nil
+	case *ast.ArrayType:
+		elem, err := g.resolve(expr.Elt, imports)
+		if err != nil {
+			return nil, err
+		}
+		if expr.Len == nil {
+			return &typ{kind: sliceKind, expr: "[]" + elem.expr, elem: elem}, nil
+		}
+		if _, ok := expr.Len.(*ast.Ellipsis); ok {
+			return nil, fmt.Errorf("invalid array length")
+		}
+		return &typ{kind: arrayKind, expr: "[" + source(expr.Len) + "]" + elem.expr, elem: elem}, nil
+	case *ast.MapType:
+		key, err := g.resolve(expr.Key, imports)
+		if err != nil {
+			return nil, err
+		}
+		elem, err := g.resolve(expr.Value, imports)
+		if err != nil {
+			return nil, err
+		}
+		return &typ{kind: mapKind, expr: "map[" + key.expr + "]" + elem.expr, key: key, elem: elem}, nil
+	case *ast.StructType:
+		t := &typ{kind: structKind}
+		var exprs []string
+		for _, f := range expr.Fields.List {
+			value := tagValue(f)
+			ft := &typ{expr: source(f.Type)} // ignored fields can have any type
+			if value != "-" {
+				var err error
+				if ft, err = g.resolve(f.Type, imports); err != nil {
+					return nil, err
+				}
+			}
+			tag := ""
+			if f.Tag != nil {
+				tag = " " + f.Tag.Value
+			}
+			names := f.Names
+			if len(names) == 0 {
+				exprs = append(exprs, ft.expr+tag)
+				names = []*ast.Ident{{Name: embeddedName(f.Type)}}
+			} else {
+				exprs = append(exprs, identNames(names)+" "+ft.expr+tag)
+			}
+			if value == "-" {
+				continue
+			} else if value == "pod" && names[0].Name == "_" {
+				return nil, fmt.Errorf("plain-old-data types are not supported")
+			}
+			for _, name := range names {
+				t.fields = append(t.fields, field{name.Name, ft})
+			}
+		}
+		t.expr = "struct{" + strings.Join(exprs, "; ") + "}"
+		return t, nil
+	}
+	return nil, fmt.Errorf("type %s is not supported", source(expr))
+}
+
+// resolveNamed describes the named type name of the package.
+func (g *generator) resolveNamed(name string) (*typ, error) {
+	spec := g.pkg.types[name]
+	if spec.TypeParams != nil {
+		return nil, fmt.Errorf("generic type %s is not supported", name)
+	}
+	if spec.Assign.IsValid() {
+		return g.resolve(spec.Type, g.pkg.imports[name])
+	}
+	t := &typ{kind: namedKind, expr: name}
+	ms := g.pkg.methodSet(name)
+	switch {
+	case g.gen[name]:
+		// encoded by the helpers its methods use
+	case ms["GotinyEncodeNested"] && ms["GotinyDecodeNested"]:
+		t.kind = nestedKind
+	case ms["GotinyEncode"] && ms["GotinyDecode"]:
+		t.kind = serializerKind
+	case ms["MarshalBinary"] && ms["UnmarshalBinary"]:
+		t.kind = binaryKind
+	case ms["GobEncode"] && ms["GobDecode"]:
+		t.kind = gobKind
+	}
+	if t.kind == namedKind {
+		if u, err := g.underlyingBasic(name); err != nil || u != nil {
+			return u, err
+		}
+		g.enqueue(name)
+	}
+	return t, nil
+}
+
+// underlyingBasic returns the description of the named type name if its
+// underlying type is a basic type, which is encoded inline.
+func (g *generator) underlyingBasic(name string) (*typ, error) {
+	expr := g.pkg.types[name].Type
+	for {
+		id, ok := expr.(*ast.Ident)
+		if !ok {
+			return nil, nil
+		}
+		spec := g.pkg.types[id.Name]
+		if spec == nil {
+			t, err := g.resolve(id, nil)
+			if err != nil {
+				return nil, err
+			}
+			t.expr = name
+			return t, nil
+		}
+		expr = spec.Type
+	}
+}
+
+func embeddedName(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.StarExpr:
+		return embeddedName(expr.X)
+	case *ast.SelectorExpr:
+		return expr.Sel.Name
+	case *ast.Ident:
+		return expr.Name
+	}
+	return ""
+}
+
+// source returns the source of the type expr as written.
+func source(expr ast.Expr) string {
+	var buf bytes.Buffer
+	printer.Fprint(&buf, token.NewFileSet(), expr)
+	return buf.String()
+}
+
+func identNames(ids []*ast.Ident) string {
+	names := make([]string, len(ids))
+	for i, id := range ids {
+		names[i] = id.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// isByte reports whether t is byte or uint8 itself, not a type defined from them.
+func isByte(t *typ) bool {
+	return t.kind == basicKind && (t.expr == "byte" || t.expr == "uint8")
+}
+
+// basicFuncs holds, for each basic type, the suffix of the methods of the
+// generated encoder and decoder that handle it and the type they take and return.
+var basicFuncs = map[string]struct{ name, typ string }{
+	"bool":       {"Bool", "bool"},
+	"int":        {"Int", "int64"},
+	"int64":      {"Int", "int64"},
+	"int8":       {"Byte", "byte"},
+	"uint8":      {"Byte", "byte"},
+	"int16":      {"Int16", "int16"},
+	"int32":      {"Int32", "int32"},
+	"uint":       {"Uint64", "uint64"},
+	"uint64":     {"Uint64", "uint64"},
+	"uintptr":    {"Uint64", "uint64"},
+	"uint16":     {"Uint16", "uint16"},
+	"uint32":     {"Uint32", "uint32"},
+	"float32":    {"Float32", "float32"},
+	"float64":    {"Float64", "float64"},
+	"complex64":  {"Complex64", "complex64"},
+	"complex128": {"Complex128", "complex128"},
+	"string":     {"String", "string"},
+}
+
+// platformFuncs holds the decoder methods for the types whose size depends on the
+// platform. They are encoded with 64 bits, and the methods panic if the decoded
+// value does not fit in the type.
+var platformFuncs = map[string]string{"int": "decPlatformInt", "uint": "decPlatformUint", "uintptr": "decPlatformUintptr"}
+
+var aliases = map[string]string{"byte": "uint8", "uint8": "byte", "rune": "int32", "int32": "rune"}
+
+// convert returns the conversion of x from type from to type to, or x if they are the same.
+func convert(to, from, x string) string {
+	if aliases[to] == from || to == aliases[from] || to == from {
+		return x
+	}
+	return to + "(" + x + ")"
+}
+
+// deref returns the value pointed to by the pointer x to a value of type t,
+// or x itself where a pointer can be used in its place.
+func deref(x string, t *typ) string {
+	switch t.kind {
+	case basicKind:
+		return "*" + x
+	case structKind, nestedKind, serializerKind, binaryKind, gobKind:
+		return x
+	}
+	return "(*" + x + ")"
+}
+
+// addr returns the address of x, as a pointer it was dereferenced from if possible.
+func addr(x string) string {
+	if strings.HasPrefix(x, "(*") && strings.HasSuffix(x, ")") {
+		return x[2 : len(x)-1]
+	}
+	return "&" + x
+}
+
+func (g *generator) local(prefix string) string {
+	g.n++
+	return fmt.Sprint(prefix, g.n)
+}
+
+// enc returns the statements that encode the value x of type t.
+func (g *generator) enc(x string, t *typ) string {
+	switch t.kind {
+	case basicKind:
+		f := basicFuncs[t.basic]
+		if f.name == "Byte" {
+			return fmt.Sprintf("e.buf = append(e.buf, %s)\n", convert("byte", t.expr, x))
+		}
+		return fmt.Sprintf("e.enc%s(%s)\n", f.name, convert(f.typ, t.expr, x))
+	case timeKind:
+		return fmt.Sprintf("e.encUint64(uint64(%s.UnixNano()))\n", x)
+	case ptrKind:
+		return fmt.Sprintf("e.encBool(%[1]s != nil)\nif %[1]s != nil {\n%s}\n", x, g.enc(deref(x, t.elem), t.elem))
+	case sliceKind:
+		if isByte(t.elem) {
+			return fmt.Sprintf("e.encBytes(%s)\n", x)
+		}
+		s := fmt.Sprintf("e.encBool(%[1]s != nil)\nif %[1]s != nil {\ne.encLength(len(%[1]s))\n", x)
+		return s + g.loop(x, t.elem) + "}\n"
+	case arrayKind:
+		if isByte(t.elem) {
+			return fmt.Sprintf("e.buf = append(e.buf, %s[:]...)\n", x)
+		}
+		return g.loop(x, t.elem)
+	case mapKind:
+		k, v := g.local("k"), g.local("v")
+		kEnc, vEnc := g.enc(k, t.key), g.enc(v, t.elem)
+		s := fmt.Sprintf("e.encBool(%[1]s != nil)\nif %[1]s != nil {\ne.encLength(len(%[1]s))\n", x)
+		switch {
+		case vEnc != "":
+			if kEnc == "" {
+				k = "_"
+			}
+			s += fmt.Sprintf("for %s, %s := range %s {\n%s%s}\n", k, v, x, kEnc, vEnc)
+		case kEnc != "":
+			s += fmt.Sprintf("for %s := range %s {\n%s}\n", k, x, kEnc)
+		}
+		return s + "}\n"
+	case structKind:
+		var s string
+		for _, f := range t.fields {
+			if f.name != "_" {
+				s += g.enc(x+"."+f.name, f.t)
+				continue
+			}
+			// blank fields hold zero values, which are encoded like any other field
+			b := g.local("b")
+			if body := g.enc(b, f.t); body != "" {
+				s += fmt.Sprintf("{\nvar %s %s\n%s}\n", b, f.t.expr, body)
+			}
+		}
+		return s
+	case namedKind:
+		return fmt.Sprintf("e.encode%s(%s)\n", t.expr, addr(x))
+	case nestedKind:
+		return fmt.Sprintf("e.buf, e.boolPos, e.boolBit = %s.GotinyEncodeNested(e.buf, e.boolPos, e.boolBit)\n", x)
+	case serializerKind:
+		return fmt.Sprintf("e.buf = %s.GotinyEncode(e.buf)\n", x)
+	case binaryKind, gobKind:
+		method := "MarshalBinary"
+		if t.kind == gobKind {
+			method = "GobEncode"
+		}
+		b := g.local("b")
+		return fmt.Sprintf("%[1]s, err := %[2]s.%[3]s()\nif err != nil {\npanic(err)\n}\ne.encLength(len(%[1]s))\ne.buf = append(e.buf, %[1]s...)\n",
+			b, x, method)
+	}
+	panic("unreachable")
+}
+
+// loop returns the statements that encode the elements of the slice or array x.
+func (g *generator) loop(x string, elem *typ) string {
+	i := g.local("i")
+	body := g.enc(x+"["+i+"]", elem)
+	if body == "" {
+		return ""
+	}
+	return fmt.Sprintf("for %s := range %s {\n%s}\n", i, x, body)
+}
+
+// dec returns the statements that decode into the variable x of type t.
+func (g *generator) dec(x string, t *typ) string {
+	switch t.kind {
+	case basicKind:
+		if name, ok := platformFuncs[t.basic]; ok {
+			return fmt.Sprintf("%s = %s\n", x, convert(t.expr, t.basic, "d."+name+"()"))
+		}
+		f := basicFuncs[t.basic]
+		return fmt.Sprintf("%s = %s\n", x, convert(t.expr, f.typ, "d.dec"+f.name+"()"))
+	case timeKind:
+		return fmt.Sprintf("%s = time.Unix(0, int64(d.decUint64()))\n", x)
+	case ptrKind:
+		return fmt.Sprintf("if d.decBool() {\nif %[1]s == nil {\n%[1]s = new(%[2]s)\n}\n%[3]s} else {\n%[1]s = nil\n}\n",
+			x, t.elem.expr, g.dec(deref(x, t.elem), t.elem))
+	case sliceKind:
+		if isByte(t.elem) {
+			return fmt.Sprintf("%s = d.decBytes()\n", x)
+		}
+		l, i := g.local("l"), g.local("i")
+		s := fmt.Sprintf("if d.decBool() {\n%[2]s := d.decLength()\nif %[1]s == nil || cap(%[1]s) < %[2]s {\n%[1]s = make(%[3]s, %[2]s)\n} else {\n%[1]s = %[1]s[:%[2]s]\n}\n",
+			x, l, t.expr)
+		if body := g.dec(x+"["+i+"]", t.elem); body != "" {
+			s += fmt.Sprintf("for %s := range %s {\n%s}\n", i, x, body)
+		}
+		return s + fmt.Sprintf("} else {\n%s = nil\n}\n", x)
+	case arrayKind:
+		if isByte(t.elem) {
+			return fmt.Sprintf("d.index += copy(%[1]s[:], d.buf[d.index:d.index+len(%[1]s)])\n", x)
+		}
+		i := g.local("i")
+		if body := g.dec(x+"["+i+"]", t.elem); body != "" {
+			return fmt.Sprintf("for %s := range %s {\n%s}\n", i, x, body)
+		}
+		return ""
+	case mapKind:
+		l, i, k, v := g.local("l"), g.local("i"), g.local("k"), g.local("v")
+		return fmt.Sprintf("if d.decBool() {\n%[2]s := d.decLength()\nif %[1]s == nil {\n%[1]s = make(%[3]s, %[2]s)\n}\n"+
+			"for %[4]s := 0; %[4]s < %[2]s; %[4]s++ {\nvar %[5]s %[7]s\nvar %[6]s %[8]s\n%[9]s%[10]s%[1]s[%[5]s] = %[6]s\n}\n} else {\n%[1]s = nil\n}\n",
+			x, l, t.expr, i, k, v, t.key.expr, t.elem.expr, g.dec(k, t.key), g.dec(v, t.elem))
+	case structKind:
+		var s string
+		for _, f := range t.fields {
+			if f.name != "_" {
+				s += g.dec(x+"."+f.name, f.t)
+				continue
+			}
+			b := g.local("b")
+			if body := g.dec(b, f.t); body != "" {
+				s += fmt.Sprintf("{\nvar %[1]s %[2]s\n%[3]s_ = %[1]s\n}\n", b, f.t.expr, body)
+			}
+		}
+		return s
+	case namedKind:
+		return fmt.Sprintf("d.decode%s(%s)\n", t.expr, addr(x))
+	case nestedKind:
+		return fmt.Sprintf("d.index, d.boolPos, d.boolBit = %s.GotinyDecodeNested(d.buf, d.index, d.boolPos, d.boolBit)\n", x)
+	case serializerKind:
+		return fmt.Sprintf("d.index += %s.GotinyDecode(d.buf[d.index:])\n", x)
+	case binaryKind, gobKind:
+		method := "UnmarshalBinary"
+		if t.kind == gobKind {
+			method = "GobDecode"
+		}
+		l := g.local("l")
+		return fmt.Sprintf("%[1]s := d.decLength()\nd.index += %[1]s\nif err := %[2]s.%[3]s(d.buf[d.index-%[1]s : d.index]); err != nil {\npanic(err)\n}\n",
+			l, x, method)
+	}
+	panic("unreachable")
+}
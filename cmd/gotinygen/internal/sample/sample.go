@@ -0,0 +1,80 @@
+// Package sample holds the types the tests of gotinygen generate methods for.
+package sample
+
+import (
+	"net/netip"
+	"time"
+)
+
+//go:generate go run github.com/niubaoshu/gotiny/cmd/gotinygen -type Order,Item
+
+type Celsius float64
+
+type Status uint8
+
+type Tags []string
+
+type Order struct {
+	ID       uint64
+	Customer string
+	Status   Status
+	Paid     bool
+	Shipped  bool
+	Items    []Item
+	Notes    *string
+	Tags     Tags
+	Attrs    map[string]int32
+	Created  time.Time
+	Timeout  time.Duration
+	Digest   [4]byte
+	Raw      []byte
+	Temp     Celsius
+	Ratio    float32
+	Signal   complex128
+	Origin   netip.Addr `gotiny:"-"`
+	Address
+	Route *Node
+	Dims  struct {
+		W, H int16
+		Unit rune
+	}
+	Counts [3]uint16
+	Seen   map[int]struct{}
+	_      int
+	cache  []int `gotiny:"-"`
+}
+
+type Item struct {
+	SKU   string
+	Qty   int
+	Price float64
+	Scale complex64
+	Flags []bool
+	Small int8
+	Stamp Stamp
+}
+
+type Address struct {
+	Street string
+	Zip    *uint32
+}
+
+// Node is a recursive type, encoded by generated helpers.
+type Node struct {
+	Name     string
+	Children []*Node
+}
+
+// Stamp is encoded by its MarshalBinary method.
+type Stamp struct {
+	Unix int64
+}
+
+func (s Stamp) MarshalBinary() ([]byte, error) {
+	return []byte{byte(s.Unix), byte(s.Unix >> 8)}, nil
+}
+
+func (s *Stamp) UnmarshalBinary(b []byte) error {
+	s.Unix = int64(b[0]) | int64(b[1])<<8
+	return nil
+}
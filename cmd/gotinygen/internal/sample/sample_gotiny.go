@@ -0,0 +1,543 @@
+// Code generated by gotinygen; DO NOT EDIT.
+
+package sample
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+type gotinyEncoder struct {
+	buf     []byte
+	boolPos int
+	boolBit byte
+}
+
+func (e *gotinyEncoder) encBool(v bool) {
+	if e.boolBit == 0 {
+		e.boolPos = len(e.buf)
+		e.buf = append(e.buf, 0)
+		e.boolBit = 1
+	}
+	if v {
+		e.buf[e.boolPos] |= e.boolBit
+	}
+	e.boolBit <<= 1
+}
+
+func (e *gotinyEncoder) encUint64(v uint64) {
+	for i := 0; i < 8 && v >= 0x80; i++ {
+		e.buf = append(e.buf, byte(v)|0x80)
+		v >>= 7
+	}
+	e.buf = append(e.buf, byte(v))
+}
+
+func (e *gotinyEncoder) encUint32(v uint32) {
+	for v >= 0x80 {
+		e.buf = append(e.buf, byte(v)|0x80)
+		v >>= 7
+	}
+	e.buf = append(e.buf, byte(v))
+}
+
+func (e *gotinyEncoder) encUint16(v uint16) { e.encUint32(uint32(v)) }
+func (e *gotinyEncoder) encLength(n int)    { e.encUint64(uint64(n)) }
+func (e *gotinyEncoder) encInt(v int64)     { e.encUint64(uint64(v<<1 ^ v>>63)) }
+func (e *gotinyEncoder) encInt32(v int32)   { e.encUint32(uint32(v<<1 ^ v>>31)) }
+func (e *gotinyEncoder) encInt16(v int16)   { e.encUint16(uint16(v<<1 ^ v>>15)) }
+
+func (e *gotinyEncoder) encFloat64(v float64) { e.encUint64(bits.ReverseBytes64(math.Float64bits(v))) }
+func (e *gotinyEncoder) encFloat32(v float32) { e.encUint32(bits.ReverseBytes32(math.Float32bits(v))) }
+
+func (e *gotinyEncoder) encComplex64(v complex64) {
+	e.encUint64(uint64(math.Float32bits(real(v))) | uint64(math.Float32bits(imag(v)))<<32)
+}
+
+func (e *gotinyEncoder) encComplex128(v complex128) {
+	e.encUint64(math.Float64bits(real(v)))
+	e.encUint64(math.Float64bits(imag(v)))
+}
+
+func (e *gotinyEncoder) encString(s string) {
+	e.encLength(len(s))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *gotinyEncoder) encBytes(b []byte) {
+	e.encBool(b != nil)
+	if b != nil {
+		e.encLength(len(b))
+		e.buf = append(e.buf, b...)
+	}
+}
+
+type gotinyDecoder struct {
+	buf     []byte
+	index   int
+	boolPos byte
+	boolBit byte
+}
+
+func (d *gotinyDecoder) decBool() bool {
+	if d.boolBit == 0 {
+		d.boolBit = 1
+		d.boolPos = d.buf[d.index]
+		d.index++
+	}
+	b := d.boolPos&d.boolBit != 0
+	d.boolBit <<= 1
+	return b
+}
+
+func (d *gotinyDecoder) decByte() byte {
+	b := d.buf[d.index]
+	d.index++
+	return b
+}
+
+func (d *gotinyDecoder) decUint64() uint64 {
+	var v uint64
+	for s := 0; s < 56; s += 7 {
+		b := d.decByte()
+		v |= uint64(b&0x7f) << s
+		if b < 0x80 {
+			return v
+		}
+	}
+	return v | uint64(d.decByte())<<56
+}
+
+func (d *gotinyDecoder) decUint32() uint32 {
+	var v uint32
+	for s := 0; ; s += 7 {
+		b := d.decByte()
+		v |= uint32(b&0x7f) << s
+		if b < 0x80 || s == 28 {
+			return v
+		}
+	}
+}
+
+func (d *gotinyDecoder) decUint16() uint16 { return uint16(d.decUint32()) }
+
+func (d *gotinyDecoder) decLength() int {
+	v := d.decUint64()
+	if v > math.MaxInt {
+		panic("gotiny: value overflows int")
+	}
+	return int(v)
+}
+
+func (d *gotinyDecoder) decInt() int64 {
+	u := d.decUint64()
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+func (d *gotinyDecoder) decPlatformInt() int {
+	v := d.decInt()
+	if int64(int(v)) != v {
+		panic("gotiny: value overflows int")
+	}
+	return int(v)
+}
+
+func (d *gotinyDecoder) decPlatformUint() uint {
+	v := d.decUint64()
+	if uint64(uint(v)) != v {
+		panic("gotiny: value overflows uint")
+	}
+	return uint(v)
+}
+
+func (d *gotinyDecoder) decPlatformUintptr() uintptr {
+	v := d.decUint64()
+	if uint64(uintptr(v)) != v {
+		panic("gotiny: value overflows uintptr")
+	}
+	return uintptr(v)
+}
+
+func (d *gotinyDecoder) decInt32() int32 {
+	u := d.decUint32()
+	return int32(u>>1) ^ -int32(u&1)
+}
+
+func (d *gotinyDecoder) decInt16() int16 {
+	u := d.decUint16()
+	return int16(u>>1) ^ -int16(u&1)
+}
+
+func (d *gotinyDecoder) decFloat64() float64 {
+	return math.Float64frombits(bits.ReverseBytes64(d.decUint64()))
+}
+
+func (d *gotinyDecoder) decFloat32() float32 {
+	return math.Float32frombits(bits.ReverseBytes32(d.decUint32()))
+}
+
+func (d *gotinyDecoder) decComplex64() complex64 {
+	u := d.decUint64()
+	return complex(math.Float32frombits(uint32(u)), math.Float32frombits(uint32(u>>32)))
+}
+
+func (d *gotinyDecoder) decComplex128() complex128 {
+	re := math.Float64frombits(d.decUint64())
+	return complex(re, math.Float64frombits(d.decUint64()))
+}
+
+func (d *gotinyDecoder) decString() string {
+	l := d.decLength()
+	s := string(d.buf[d.index : d.index+l])
+	d.index += l
+	return s
+}
+
+func (d *gotinyDecoder) decBytes() []byte {
+	if !d.decBool() {
+		return nil
+	}
+	l := d.decLength()
+	b := make([]byte, l)
+	d.index += copy(b, d.buf[d.index:d.index+l])
+	return b
+}
+
+// GotinyEncode appends the gotiny encoding of v to buf.
+func (v *Order) GotinyEncode(buf []byte) []byte {
+	e := gotinyEncoder{buf: buf}
+	e.encodeOrder(v)
+	return e.buf
+}
+
+// GotinyDecode decodes buf into v and returns the number of bytes read.
+func (v *Order) GotinyDecode(buf []byte) int {
+	d := gotinyDecoder{buf: buf}
+	d.decodeOrder(v)
+	return d.index
+}
+
+// GotinyEncodeNested appends the gotiny encoding of v to buf as part of an
+// enclosing value, whose byte of bools is at buf[boolPos] if boolBit is not 0.
+// It returns the extended buffer and the new state of the bools.
+func (v *Order) GotinyEncodeNested(buf []byte, boolPos int, boolBit byte) ([]byte, int, byte) {
+	e := gotinyEncoder{buf: buf, boolPos: boolPos, boolBit: boolBit}
+	e.encodeOrder(v)
+	return e.buf, e.boolPos, e.boolBit
+}
+
+// GotinyDecodeNested decodes buf[index:] into v as part of an enclosing value,
+// whose byte of bools is boolByte if boolBit is not 0. It returns the index
+// of the next byte to read and the new state of the bools.
+func (v *Order) GotinyDecodeNested(buf []byte, index int, boolByte, boolBit byte) (int, byte, byte) {
+	d := gotinyDecoder{buf: buf, index: index, boolPos: boolByte, boolBit: boolBit}
+	d.decodeOrder(v)
+	return d.index, d.boolPos, d.boolBit
+}
+
+// GotinyEncode appends the gotiny encoding of v to buf.
+func (v *Item) GotinyEncode(buf []byte) []byte {
+	e := gotinyEncoder{buf: buf}
+	e.encodeItem(v)
+	return e.buf
+}
+
+// GotinyDecode decodes buf into v and returns the number of bytes read.
+func (v *Item) GotinyDecode(buf []byte) int {
+	d := gotinyDecoder{buf: buf}
+	d.decodeItem(v)
+	return d.index
+}
+
+// GotinyEncodeNested appends the gotiny encoding of v to buf as part of an
+// enclosing value, whose byte of bools is at buf[boolPos] if boolBit is not 0.
+// It returns the extended buffer and the new state of the bools.
+func (v *Item) GotinyEncodeNested(buf []byte, boolPos int, boolBit byte) ([]byte, int, byte) {
+	e := gotinyEncoder{buf: buf, boolPos: boolPos, boolBit: boolBit}
+	e.encodeItem(v)
+	return e.buf, e.boolPos, e.boolBit
+}
+
+// GotinyDecodeNested decodes buf[index:] into v as part of an enclosing value,
+// whose byte of bools is boolByte if boolBit is not 0. It returns the index
+// of the next byte to read and the new state of the bools.
+func (v *Item) GotinyDecodeNested(buf []byte, index int, boolByte, boolBit byte) (int, byte, byte) {
+	d := gotinyDecoder{buf: buf, index: index, boolPos: boolByte, boolBit: boolBit}
+	d.decodeItem(v)
+	return d.index, d.boolPos, d.boolBit
+}
+
+func (e *gotinyEncoder) encodeOrder(v *Order) {
+	e.encUint64(v.ID)
+	e.encString(v.Customer)
+	e.buf = append(e.buf, byte(v.Status))
+	e.encBool(v.Paid)
+	e.encBool(v.Shipped)
+	e.encBool(v.Items != nil)
+	if v.Items != nil {
+		e.encLength(len(v.Items))
+		for i1 := range v.Items {
+			e.encodeItem(&v.Items[i1])
+		}
+	}
+	e.encBool(v.Notes != nil)
+	if v.Notes != nil {
+		e.encString(*v.Notes)
+	}
+	e.encodeTags(&v.Tags)
+	e.encBool(v.Attrs != nil)
+	if v.Attrs != nil {
+		e.encLength(len(v.Attrs))
+		for k2, v3 := range v.Attrs {
+			e.encString(k2)
+			e.encInt32(v3)
+		}
+	}
+	e.encUint64(uint64(v.Created.UnixNano()))
+	e.encInt(int64(v.Timeout))
+	e.buf = append(e.buf, v.Digest[:]...)
+	e.encBytes(v.Raw)
+	e.encFloat64(float64(v.Temp))
+	e.encFloat32(v.Ratio)
+	e.encComplex128(v.Signal)
+	e.encodeAddress(&v.Address)
+	e.encBool(v.Route != nil)
+	if v.Route != nil {
+		e.encodeNode(v.Route)
+	}
+	e.encInt16(v.Dims.W)
+	e.encInt16(v.Dims.H)
+	e.encInt32(v.Dims.Unit)
+	for i4 := range v.Counts {
+		e.encUint16(v.Counts[i4])
+	}
+	e.encBool(v.Seen != nil)
+	if v.Seen != nil {
+		e.encLength(len(v.Seen))
+		for k5 := range v.Seen {
+			e.encInt(int64(k5))
+		}
+	}
+	{
+		var b7 int
+		e.encInt(int64(b7))
+	}
+}
+
+func (d *gotinyDecoder) decodeOrder(v *Order) {
+	v.ID = d.decUint64()
+	v.Customer = d.decString()
+	v.Status = Status(d.decByte())
+	v.Paid = d.decBool()
+	v.Shipped = d.decBool()
+	if d.decBool() {
+		l8 := d.decLength()
+		if v.Items == nil || cap(v.Items) < l8 {
+			v.Items = make([]Item, l8)
+		} else {
+			v.Items = v.Items[:l8]
+		}
+		for i9 := range v.Items {
+			d.decodeItem(&v.Items[i9])
+		}
+	} else {
+		v.Items = nil
+	}
+	if d.decBool() {
+		if v.Notes == nil {
+			v.Notes = new(string)
+		}
+		*v.Notes = d.decString()
+	} else {
+		v.Notes = nil
+	}
+	d.decodeTags(&v.Tags)
+	if d.decBool() {
+		l10 := d.decLength()
+		if v.Attrs == nil {
+			v.Attrs = make(map[string]int32, l10)
+		}
+		for i11 := 0; i11 < l10; i11++ {
+			var k12 string
+			var v13 int32
+			k12 = d.decString()
+			v13 = d.decInt32()
+			v.Attrs[k12] = v13
+		}
+	} else {
+		v.Attrs = nil
+	}
+	v.Created = time.Unix(0, int64(d.decUint64()))
+	v.Timeout = time.Duration(d.decInt())
+	d.index += copy(v.Digest[:], d.buf[d.index:d.index+len(v.Digest)])
+	v.Raw = d.decBytes()
+	v.Temp = Celsius(d.decFloat64())
+	v.Ratio = d.decFloat32()
+	v.Signal = d.decComplex128()
+	d.decodeAddress(&v.Address)
+	if d.decBool() {
+		if v.Route == nil {
+			v.Route = new(Node)
+		}
+		d.decodeNode(v.Route)
+	} else {
+		v.Route = nil
+	}
+	v.Dims.W = d.decInt16()
+	v.Dims.H = d.decInt16()
+	v.Dims.Unit = d.decInt32()
+	for i14 := range v.Counts {
+		v.Counts[i14] = d.decUint16()
+	}
+	if d.decBool() {
+		l15 := d.decLength()
+		if v.Seen == nil {
+			v.Seen = make(map[int]struct{}, l15)
+		}
+		for i16 := 0; i16 < l15; i16++ {
+			var k17 int
+			var v18 struct{}
+			k17 = d.decPlatformInt()
+			v.Seen[k17] = v18
+		}
+	} else {
+		v.Seen = nil
+	}
+	{
+		var b19 int
+		b19 = d.decPlatformInt()
+		_ = b19
+	}
+}
+
+func (e *gotinyEncoder) encodeItem(v *Item) {
+	e.encString(v.SKU)
+	e.encInt(int64(v.Qty))
+	e.encFloat64(v.Price)
+	e.encComplex64(v.Scale)
+	e.encBool(v.Flags != nil)
+	if v.Flags != nil {
+		e.encLength(len(v.Flags))
+		for i20 := range v.Flags {
+			e.encBool(v.Flags[i20])
+		}
+	}
+	e.buf = append(e.buf, byte(v.Small))
+	b21, err := v.Stamp.MarshalBinary()
+	if err != nil {
+		panic(err)
+	}
+	e.encLength(len(b21))
+	e.buf = append(e.buf, b21...)
+}
+
+func (d *gotinyDecoder) decodeItem(v *Item) {
+	v.SKU = d.decString()
+	v.Qty = d.decPlatformInt()
+	v.Price = d.decFloat64()
+	v.Scale = d.decComplex64()
+	if d.decBool() {
+		l22 := d.decLength()
+		if v.Flags == nil || cap(v.Flags) < l22 {
+			v.Flags = make([]bool, l22)
+		} else {
+			v.Flags = v.Flags[:l22]
+		}
+		for i23 := range v.Flags {
+			v.Flags[i23] = d.decBool()
+		}
+	} else {
+		v.Flags = nil
+	}
+	v.Small = int8(d.decByte())
+	l24 := d.decLength()
+	d.index += l24
+	if err := v.Stamp.UnmarshalBinary(d.buf[d.index-l24 : d.index]); err != nil {
+		panic(err)
+	}
+}
+
+func (e *gotinyEncoder) encodeTags(v *Tags) {
+	e.encBool((*v) != nil)
+	if (*v) != nil {
+		e.encLength(len((*v)))
+		for i25 := range *v {
+			e.encString((*v)[i25])
+		}
+	}
+}
+
+func (d *gotinyDecoder) decodeTags(v *Tags) {
+	if d.decBool() {
+		l26 := d.decLength()
+		if (*v) == nil || cap((*v)) < l26 {
+			(*v) = make([]string, l26)
+		} else {
+			(*v) = (*v)[:l26]
+		}
+		for i27 := range *v {
+			(*v)[i27] = d.decString()
+		}
+	} else {
+		(*v) = nil
+	}
+}
+
+func (e *gotinyEncoder) encodeAddress(v *Address) {
+	e.encString(v.Street)
+	e.encBool(v.Zip != nil)
+	if v.Zip != nil {
+		e.encUint32(*v.Zip)
+	}
+}
+
+func (d *gotinyDecoder) decodeAddress(v *Address) {
+	v.Street = d.decString()
+	if d.decBool() {
+		if v.Zip == nil {
+			v.Zip = new(uint32)
+		}
+		*v.Zip = d.decUint32()
+	} else {
+		v.Zip = nil
+	}
+}
+
+func (e *gotinyEncoder) encodeNode(v *Node) {
+	e.encString(v.Name)
+	e.encBool(v.Children != nil)
+	if v.Children != nil {
+		e.encLength(len(v.Children))
+		for i28 := range v.Children {
+			e.encBool(v.Children[i28] != nil)
+			if v.Children[i28] != nil {
+				e.encodeNode(v.Children[i28])
+			}
+		}
+	}
+}
+
+func (d *gotinyDecoder) decodeNode(v *Node) {
+	v.Name = d.decString()
+	if d.decBool() {
+		l29 := d.decLength()
+		if v.Children == nil || cap(v.Children) < l29 {
+			v.Children = make([]*Node, l29)
+		} else {
+			v.Children = v.Children[:l29]
+		}
+		for i30 := range v.Children {
+			if d.decBool() {
+				if v.Children[i30] == nil {
+					v.Children[i30] = new(Node)
+				}
+				d.decodeNode(v.Children[i30])
+			} else {
+				v.Children[i30] = nil
+			}
+		}
+	} else {
+		v.Children = nil
+	}
+}
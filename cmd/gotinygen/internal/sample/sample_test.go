@@ -0,0 +1,129 @@
+package sample
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/niubaoshu/gotiny"
+)
+
+// plainOrder and plainItem have the fields of Order and Item but not their
+// generated methods, so gotiny encodes them with its reflection engines.
+type (
+	plainOrder Order
+	plainItem  Item
+)
+
+func newOrder() Order {
+	note, zip := "leave at door", uint32(8001)
+	return Order{
+		ID:       1 << 40,
+		Customer: "Ada",
+		Status:   3,
+		Paid:     true,
+		Items: []Item{
+			{SKU: "A-1", Qty: -2, Price: 9.99, Scale: complex(1.5, -2), Flags: []bool{true, false, true}, Small: -7, Stamp: Stamp{513}},
+			{SKU: "B-2", Qty: 1 << 30, Price: -0.5, Flags: []bool{}},
+		},
+		Notes:   &note,
+		Tags:    Tags{"gift", ""},
+		Attrs:   map[string]int32{"w": -40000},
+		Created: time.Unix(1700000000, 12345),
+		Timeout: -3 * time.Second,
+		Digest:  [4]byte{1, 2, 3, 255},
+		Raw:     []byte{},
+		Temp:    -12.25,
+		Ratio:   0.1,
+		Signal:  complex(3, 1e300),
+		Address: Address{Street: "Main St", Zip: &zip},
+		Route:   &Node{Name: "root", Children: []*Node{{Name: "a"}, nil, {Name: "b", Children: []*Node{}}}},
+		Counts:  [3]uint16{0, 300, 65535},
+		Seen:    map[int]struct{}{-1: {}},
+	}
+}
+
+func TestCompatible(t *testing.T) {
+	for _, v := range []Order{newOrder(), {}} {
+		got := gotiny.Marshal(&v)
+		want := gotiny.Marshal((*plainOrder)(&v))
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("generated encoding\n%v\ndiffers from reflection encoding\n%v", got, want)
+		}
+
+		var fromGen plainOrder
+		if n := gotiny.Unmarshal(got, &fromGen); n != len(got) {
+			t.Fatalf("reflection decoded %d of %d bytes", n, len(got))
+		}
+		var fromReflect Order
+		if n := gotiny.Unmarshal(want, &fromReflect); n != len(want) {
+			t.Fatalf("generated code decoded %d of %d bytes", n, len(want))
+		}
+		if !reflect.DeepEqual(Order(fromGen), fromReflect) {
+			t.Fatalf("decoded values differ:\n%+v\n%+v", fromGen, fromReflect)
+		}
+	}
+
+	item := newOrder().Items[0]
+	if got, want := gotiny.Marshal(&item), gotiny.Marshal((*plainItem)(&item)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("generated encoding\n%v\ndiffers from reflection encoding\n%v", got, want)
+	}
+}
+
+// outer and plainOuter embed an Order between bools, whose bits the nested
+// encodings share.
+type (
+	outer struct {
+		Flag  bool
+		Order Order
+		Items []Item
+		Tail  bool
+	}
+	plainOuter struct {
+		Flag  bool
+		Order plainOrder
+		Items []plainItem
+		Tail  bool
+	}
+)
+
+func TestCompatibleNested(t *testing.T) {
+	o := newOrder()
+	v := outer{Flag: true, Order: o, Items: o.Items, Tail: true}
+	got := gotiny.Marshal(&v)
+	want := gotiny.Marshal(&plainOuter{Flag: true, Order: plainOrder(o), Items: []plainItem{plainItem(o.Items[0]), plainItem(o.Items[1])}, Tail: true})
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("generated encoding\n%v\ndiffers from reflection encoding\n%v", got, want)
+	}
+
+	var fromGen plainOuter
+	if n := gotiny.Unmarshal(got, &fromGen); n != len(got) {
+		t.Fatalf("reflection decoded %d of %d bytes", n, len(got))
+	}
+	var fromReflect outer
+	if n := gotiny.Unmarshal(want, &fromReflect); n != len(want) {
+		t.Fatalf("generated code decoded %d of %d bytes", n, len(want))
+	}
+	if !fromGen.Flag || !fromGen.Tail || !fromReflect.Flag || !fromReflect.Tail {
+		t.Fatalf("bools around the order were lost: %v %v", fromGen, fromReflect)
+	}
+	if !reflect.DeepEqual(Order(fromGen.Order), fromReflect.Order) {
+		t.Fatalf("decoded orders differ:\n%+v\n%+v", fromGen.Order, fromReflect.Order)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	v := newOrder()
+	buf := v.GotinyEncode([]byte{0xAA})
+	var got Order
+	if n := got.GotinyDecode(buf[1:]); n != len(buf)-1 {
+		t.Fatalf("decoded %d of %d bytes", n, len(buf)-1)
+	}
+	if !got.Created.Equal(v.Created) {
+		t.Fatalf("got time %v, want %v", got.Created, v.Created)
+	}
+	got.Created = v.Created
+	if !reflect.DeepEqual(got, v) {
+		t.Fatalf("got\n%+v\nwant\n%+v", got, v)
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Gotinygen generates GotinyEncode and GotinyDecode methods, which implement
+gotiny.Serializer, for the named types of a Go package. The generated methods
+produce exactly the bytes the reflection engines of gotiny produce for the same
+types, so values encoded by one can be decoded by the other. They use neither
+reflect nor unsafe, and the generated file does not import gotiny.
+
+Gotinygen also generates GotinyEncodeNested and GotinyDecodeNested methods.
+Gotiny calls them for a value nested in another one, passing the state of the
+byte of bools the enclosing value is filling, so the nested bytes are the same
+as well. Canonical encoders, packed floats and decoders that do not reject
+oversized ints use the reflection engines for these types instead.
+
+Usage:
+
+	gotinygen -type T1,T2 [-output file] [dir]
+
+It is meant to be run by go generate, with a directive such as
+
+	//go:generate go run github.com/niubaoshu/gotiny/cmd/gotinygen -type Order
+
+in a file of the package. By default the methods are written to
+<package>_gotiny.go in the package directory.
+
+Fields and elements of the following types are supported: booleans, numbers,
+strings, time.Time, time.Duration, pointers, arrays, slices, maps and structs
+built from them, and named types of the package. Named types that implement
+gotiny.Serializer, encoding.BinaryMarshaler or gob.GobEncoder are encoded with
+those methods, as gotiny does. Interfaces, channels, functions, types of other
+packages and types registered with gotiny.RegisterPOD are not supported. Floats
+are encoded as by gotiny.Marshal, and maps in iteration order, not canonically.
+GotinyDecode panics if an int, uint or uintptr does not fit in the type on the
+decoding platform, as gotiny.Unmarshal does by default.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	typeNames = flag.String("type", "", "comma-separated list of type names; must be set")
+	output    = flag.String("output", "", "output file name; default <package>_gotiny.go")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: gotinygen -type T1,T2 [-output file] [dir]\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if *typeNames == "" || flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	dir := "."
+	if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	}
+	if err := run(dir, strings.Split(*typeNames, ","), *output); err != nil {
+		fmt.Fprintf(os.Stderr, "gotinygen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run generates the methods of the types named names in the package in dir.
+func run(dir string, names []string, out string) error {
+	pkg, err := parsePackage(dir, out)
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		out = pkg.name + "_gotiny.go"
+	}
+	src, err := generate(pkg, names)
+	if err != nil {
+		return err
+	}
+	if src, err = format.Source(src); err != nil {
+		return fmt.Errorf("formatting generated code: %v", err)
+	}
+	return os.WriteFile(filepath.Join(dir, filepath.Base(out)), src, 0o644)
+}
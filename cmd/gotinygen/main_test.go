@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGolden checks that the generated code of the sample package is up to date.
+func TestGolden(t *testing.T) {
+	dir := t.TempDir()
+	src, err := os.ReadFile("internal/sample/sample.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sample.go"), src, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(dir, []string{"Order", "Item"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "sample_gotiny.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("internal/sample/sample_gotiny.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("internal/sample/sample_gotiny.go is out of date; run go generate ./cmd/gotinygen/internal/sample")
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	for _, tc := range []struct{ src, err string }{
+		{"type T struct{ F any }", "type any is not supported"},
+		{"type T struct{ C chan int }", "type chan int is not supported"},
+		{"import \"bytes\"\ntype T struct{ B bytes.Buffer }", "type bytes.Buffer of another package is not supported"},
+		{"type T struct{ _ struct{} `gotiny:\"pod\"`; X int }", "plain-old-data types are not supported"},
+		{"type U int\ntype T = U", "aliases and generic types are not supported"},
+		{"type T struct{}", ""},
+		{"type T struct{ C chan int `gotiny:\"-\"` }", ""},
+	} {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "t.go"), []byte("package p\n"+tc.src+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		err := run(dir, []string{"T"}, "")
+		if tc.err == "" && err != nil || tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Errorf("%q: got error %v, want %q", tc.src, err, tc.err)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// pkgInfo holds the declarations of the package the methods are generated for.
+type pkgInfo struct {
+	name    string
+	types   map[string]*ast.TypeSpec     // type declarations by name
+	imports map[string]map[string]string // imports of the file declaring each type, by local name
+	methods map[string]map[string]bool   // names of the methods declared on each type or pointer to it
+	podTag  map[string]bool              // types with a blank field tagged gotiny:"pod"
+	cache   map[string]map[string]bool   // method sets computed by methodSet
+}
+
+// parsePackage parses the non-test Go files in dir, except the file out, which
+// holds the previously generated methods.
+func parsePackage(dir, out string) (*pkgInfo, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	pkg := &pkgInfo{
+		types:   map[string]*ast.TypeSpec{},
+		imports: map[string]map[string]string{},
+		methods: map[string]map[string]bool{},
+		podTag:  map[string]bool{},
+		cache:   map[string]map[string]bool{},
+	}
+	fset := token.NewFileSet()
+	for _, path := range paths {
+		base := filepath.Base(path)
+		if strings.HasSuffix(base, "_test.go") || (out != "" && base == filepath.Base(out)) {
+			continue
+		}
+		f, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		if pkg.name == "" {
+			pkg.name = f.Name.Name
+		} else if f.Name.Name != pkg.name {
+			return nil, fmt.Errorf("%s: package %s, expected %s", path, f.Name.Name, pkg.name)
+		}
+		if out == "" && base == pkg.name+"_gotiny.go" {
+			continue
+		}
+		pkg.addFile(f)
+	}
+	if pkg.name == "" {
+		return nil, fmt.Errorf("no Go files in %s", dir)
+	}
+	return pkg, nil
+}
+
+func (pkg *pkgInfo) addFile(f *ast.File) {
+	imports := map[string]string{}
+	for _, spec := range f.Imports {
+		path, _ := strconv.Unquote(spec.Path.Value)
+		name := path[strings.LastIndex(path, "/")+1:]
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		imports[name] = path
+	}
+	for _, decl := range f.Decls {
+		switch decl := decl.(type) {
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				if ts, ok := spec.(*ast.TypeSpec); ok {
+					pkg.types[ts.Name.Name] = ts
+					pkg.imports[ts.Name.Name] = imports
+					if st, ok := ts.Type.(*ast.StructType); ok && hasPODTag(st) {
+						pkg.podTag[ts.Name.Name] = true
+					}
+				}
+			}
+		case *ast.FuncDecl:
+			if decl.Recv == nil || len(decl.Recv.List) != 1 {
+				continue
+			}
+			recv := decl.Recv.List[0].Type
+			if star, ok := recv.(*ast.StarExpr); ok {
+				recv = star.X
+			}
+			if index, ok := recv.(*ast.IndexExpr); ok {
+				recv = index.X
+			}
+			if id, ok := recv.(*ast.Ident); ok {
+				if pkg.methods[id.Name] == nil {
+					pkg.methods[id.Name] = map[string]bool{}
+				}
+				pkg.methods[id.Name][decl.Name.Name] = true
+			}
+		}
+	}
+}
+
+// hasPODTag reports whether st has a blank field tagged gotiny:"pod".
+func hasPODTag(st *ast.StructType) bool {
+	for _, field := range st.Fields.List {
+		if len(field.Names) == 1 && field.Names[0].Name == "_" && tagValue(field) == "pod" {
+			return true
+		}
+	}
+	return false
+}
+
+// tagValue returns the value of the gotiny key in the tag of field.
+func tagValue(field *ast.Field) string {
+	if field.Tag == nil {
+		return ""
+	}
+	tag, _ := strconv.Unquote(field.Tag.Value)
+	value, _ := reflect.StructTag(tag).Lookup("gotiny")
+	return strings.TrimSpace(value)
+}
+
+// timeMethods are the methods of time.Time that gotiny looks for.
+var timeMethods = map[string]bool{
+	"MarshalBinary": true, "UnmarshalBinary": true, "GobEncode": true, "GobDecode": true,
+}
+
+// methodSet returns the names of the methods of the pointer to the named type
+// name, including those promoted from embedded fields.
+func (pkg *pkgInfo) methodSet(name string) map[string]bool {
+	if ms, ok := pkg.cache[name]; ok {
+		return ms
+	}
+	ms := map[string]bool{}
+	pkg.cache[name] = ms // breaks cycles through embedded pointers
+	for m := range pkg.methods[name] {
+		ms[m] = true
+	}
+	spec := pkg.types[name]
+	if spec == nil {
+		return ms
+	}
+	st, ok := spec.Type.(*ast.StructType)
+	if !ok {
+		return ms
+	}
+	for _, field := range st.Fields.List {
+		if len(field.Names) != 0 {
+			continue
+		}
+		t := field.Type
+		if star, ok := t.(*ast.StarExpr); ok {
+			t = star.X
+		}
+		var promoted map[string]bool
+		switch t := t.(type) {
+		case *ast.Ident:
+			promoted = pkg.methodSet(t.Name)
+		case *ast.SelectorExpr:
+			if x, ok := t.X.(*ast.Ident); ok && pkg.imports[name][x.Name] == "time" && t.Sel.Name == "Time" {
+				promoted = timeMethods
+			}
+		}
+		for m := range promoted {
+			ms[m] = true
+		}
+	}
+	return ms
+}
@@ -0,0 +1,204 @@
+package main
+
+// runtime is emitted at the top of every generated file. It holds the base
+// encoding functions of gotiny (encbase.go and decbase.go) rewritten without
+// unsafe, on an encoder and a decoder that only carry the buffer and the state
+// of the bit-packed bools. The generated methods call nothing else, so the
+// generated package does not import gotiny.
+const runtime = `
+type gotinyEncoder struct {
+	buf     []byte
+	boolPos int
+	boolBit byte
+}
+
+func (e *gotinyEncoder) encBool(v bool) {
+	if e.boolBit == 0 {
+		e.boolPos = len(e.buf)
+		e.buf = append(e.buf, 0)
+		e.boolBit = 1
+	}
+	if v {
+		e.buf[e.boolPos] |= e.boolBit
+	}
+	e.boolBit <<= 1
+}
+
+func (e *gotinyEncoder) encUint64(v uint64) {
+	for i := 0; i < 8 && v >= 0x80; i++ {
+		e.buf = append(e.buf, byte(v)|0x80)
+		v >>= 7
+	}
+	e.buf = append(e.buf, byte(v))
+}
+
+func (e *gotinyEncoder) encUint32(v uint32) {
+	for v >= 0x80 {
+		e.buf = append(e.buf, byte(v)|0x80)
+		v >>= 7
+	}
+	e.buf = append(e.buf, byte(v))
+}
+
+func (e *gotinyEncoder) encUint16(v uint16) { e.encUint32(uint32(v)) }
+func (e *gotinyEncoder) encLength(n int)    { e.encUint64(uint64(n)) }
+func (e *gotinyEncoder) encInt(v int64)     { e.encUint64(uint64(v<<1 ^ v>>63)) }
+func (e *gotinyEncoder) encInt32(v int32)   { e.encUint32(uint32(v<<1 ^ v>>31)) }
+func (e *gotinyEncoder) encInt16(v int16)   { e.encUint16(uint16(v<<1 ^ v>>15)) }
+
+func (e *gotinyEncoder) encFloat64(v float64) { e.encUint64(bits.ReverseBytes64(math.Float64bits(v))) }
+func (e *gotinyEncoder) encFloat32(v float32) { e.encUint32(bits.ReverseBytes32(math.Float32bits(v))) }
+
+func (e *gotinyEncoder) encComplex64(v complex64) {
+	e.encUint64(uint64(math.Float32bits(real(v))) | uint64(math.Float32bits(imag(v)))<<32)
+}
+
+func (e *gotinyEncoder) encComplex128(v complex128) {
+	e.encUint64(math.Float64bits(real(v)))
+	e.encUint64(math.Float64bits(imag(v)))
+}
+
+func (e *gotinyEncoder) encString(s string) {
+	e.encLength(len(s))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *gotinyEncoder) encBytes(b []byte) {
+	e.encBool(b != nil)
+	if b != nil {
+		e.encLength(len(b))
+		e.buf = append(e.buf, b...)
+	}
+}
+
+type gotinyDecoder struct {
+	buf     []byte
+	index   int
+	boolPos byte
+	boolBit byte
+}
+
+func (d *gotinyDecoder) decBool() bool {
+	if d.boolBit == 0 {
+		d.boolBit = 1
+		d.boolPos = d.buf[d.index]
+		d.index++
+	}
+	b := d.boolPos&d.boolBit != 0
+	d.boolBit <<= 1
+	return b
+}
+
+func (d *gotinyDecoder) decByte() byte {
+	b := d.buf[d.index]
+	d.index++
+	return b
+}
+
+func (d *gotinyDecoder) decUint64() uint64 {
+	var v uint64
+	for s := 0; s < 56; s += 7 {
+		b := d.decByte()
+		v |= uint64(b&0x7f) << s
+		if b < 0x80 {
+			return v
+		}
+	}
+	return v | uint64(d.decByte())<<56
+}
+
+func (d *gotinyDecoder) decUint32() uint32 {
+	var v uint32
+	for s := 0; ; s += 7 {
+		b := d.decByte()
+		v |= uint32(b&0x7f) << s
+		if b < 0x80 || s == 28 {
+			return v
+		}
+	}
+}
+
+func (d *gotinyDecoder) decUint16() uint16 { return uint16(d.decUint32()) }
+
+func (d *gotinyDecoder) decLength() int {
+	v := d.decUint64()
+	if v > math.MaxInt {
+		panic("gotiny: value overflows int")
+	}
+	return int(v)
+}
+
+func (d *gotinyDecoder) decInt() int64 {
+	u := d.decUint64()
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+func (d *gotinyDecoder) decPlatformInt() int {
+	v := d.decInt()
+	if int64(int(v)) != v {
+		panic("gotiny: value overflows int")
+	}
+	return int(v)
+}
+
+func (d *gotinyDecoder) decPlatformUint() uint {
+	v := d.decUint64()
+	if uint64(uint(v)) != v {
+		panic("gotiny: value overflows uint")
+	}
+	return uint(v)
+}
+
+func (d *gotinyDecoder) decPlatformUintptr() uintptr {
+	v := d.decUint64()
+	if uint64(uintptr(v)) != v {
+		panic("gotiny: value overflows uintptr")
+	}
+	return uintptr(v)
+}
+
+func (d *gotinyDecoder) decInt32() int32 {
+	u := d.decUint32()
+	return int32(u>>1) ^ -int32(u&1)
+}
+
+func (d *gotinyDecoder) decInt16() int16 {
+	u := d.decUint16()
+	return int16(u>>1) ^ -int16(u&1)
+}
+
+func (d *gotinyDecoder) decFloat64() float64 {
+	return math.Float64frombits(bits.ReverseBytes64(d.decUint64()))
+}
+
+func (d *gotinyDecoder) decFloat32() float32 {
+	return math.Float32frombits(bits.ReverseBytes32(d.decUint32()))
+}
+
+func (d *gotinyDecoder) decComplex64() complex64 {
+	u := d.decUint64()
+	return complex(math.Float32frombits(uint32(u)), math.Float32frombits(uint32(u>>32)))
+}
+
+func (d *gotinyDecoder) decComplex128() complex128 {
+	re := math.Float64frombits(d.decUint64())
+	return complex(re, math.Float64frombits(d.decUint64()))
+}
+
+func (d *gotinyDecoder) decString() string {
+	l := d.decLength()
+	s := string(d.buf[d.index : d.index+l])
+	d.index += l
+	return s
+}
+
+func (d *gotinyDecoder) decBytes() []byte {
+	if !d.decBool() {
+		return nil
+	}
+	l := d.decLength()
+	b := make([]byte, l)
+	d.index += copy(b, d.buf[d.index:d.index+l])
+	return b
+}
+`
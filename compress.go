@@ -0,0 +1,172 @@
+package gotiny
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Compression is an algorithm that compresses the payload of an envelope.
+type Compression uint8
+
+const (
+	NoCompression Compression = iota
+	Flate                     // compress/flate, raw DEFLATE
+	Gzip                      // compress/gzip
+	Zlib                      // compress/zlib
+)
+
+func (c Compression) String() string {
+	switch c {
+	case NoCompression:
+		return "none"
+	case Flate:
+		return "flate"
+	case Gzip:
+		return "gzip"
+	case Zlib:
+		return "zlib"
+	}
+	return fmt.Sprintf("Compression(%d)", uint8(c))
+}
+
+// DefaultCompressThreshold is the length from which payloads are compressed when
+// EnvelopeOptions.CompressThreshold is 0. Shorter ones rarely get any shorter.
+const DefaultCompressThreshold = 128
+
+// MarshalCompressed encodes the values pointed to by ps like Marshal and returns them
+// in an envelope whose payload is compressed with c, unless it is shorter than
+// DefaultCompressThreshold. Use MarshalEnvelope to choose the level or the threshold.
+func MarshalCompressed(c Compression, ps ...any) []byte {
+	return MarshalEnvelope(EnvelopeOptions{Compression: c}, ps...)
+}
+
+// UnmarshalCompressed decodes an envelope written by MarshalCompressed, or by
+// MarshalEnvelope with any options, into the values pointed to by is. It is the same
+// as UnmarshalEnvelope, which detects the compression from the header.
+func UnmarshalCompressed(buf []byte, is ...any) (int, error) {
+	return UnmarshalEnvelope(buf, is...)
+}
+
+// compressor is implemented by the writers of compress/flate, gzip and zlib.
+type compressor interface {
+	io.WriteCloser
+	Reset(io.Writer)
+}
+
+// compressorPools pools the compressors of each algorithm and level, which are
+// expensive to create, by compressorIndex.
+type compressorPools [4 * (flate.BestCompression - flate.HuffmanOnly + 1)]sync.Pool
+
+// compressors holds the compressors without a preset dictionary. Those with one
+// are pooled with the dictionary.
+var compressors compressorPools
+
+func compressorIndex(c Compression, level int) int {
+	return int(c)*(flate.BestCompression-flate.HuffmanOnly+1) + level - flate.HuffmanOnly
+}
+
+// appendWriter is an io.Writer that appends to buf.
+type appendWriter struct{ buf []byte }
+
+func (w *appendWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	return len(p), nil
+}
+
+// compress compresses payload as opts say, and reports whether it should be stored
+// compressed, which is not the case if it is too short to be compressed or if
+// compression does not make it shorter.
+func compress(opts EnvelopeOptions, payload []byte) ([]byte, bool) {
+	threshold := opts.CompressThreshold
+	if threshold == 0 && opts.Dictionary == 0 {
+		threshold = DefaultCompressThreshold
+	}
+	if opts.Compression == NoCompression || len(payload) < threshold {
+		return nil, false
+	}
+	level := opts.CompressionLevel
+	if level == 0 && opts.Dictionary != 0 {
+		// the levels below 7 of compress/flate find no matches in inputs shorter
+		// than a few hundred bytes, which are what dictionaries are meant for
+		level = flate.BestCompression
+	} else if level == 0 {
+		level = flate.DefaultCompression
+	}
+	if opts.Compression > Zlib || level < flate.HuffmanOnly || level > flate.BestCompression {
+		panic(fmt.Sprintf("gotiny: invalid compression %v at level %d", opts.Compression, level))
+	}
+	pools, dict := &compressors, []byte(nil)
+	if opts.Dictionary != 0 {
+		d := getDictionary(opts.Dictionary)
+		if d == nil {
+			panic(fmt.Sprintf("gotiny: dictionary %d is not registered", opts.Dictionary))
+		}
+		if opts.Compression == Gzip {
+			panic("gotiny: gzip does not support preset dictionaries")
+		}
+		pools, dict = &d.compressors, d.data
+	}
+	out := &appendWriter{buf: make([]byte, 0, len(payload)/2)}
+	pool := &pools[compressorIndex(opts.Compression, level)]
+	w, _ := pool.Get().(compressor)
+	if w != nil {
+		w.Reset(out) // keeps the dictionary
+	} else {
+		switch opts.Compression {
+		case Flate:
+			w, _ = flate.NewWriterDict(out, level, dict)
+		case Gzip:
+			w, _ = gzip.NewWriterLevel(out, level)
+		case Zlib:
+			w, _ = zlib.NewWriterLevelDict(out, level, dict)
+		}
+	}
+	// writing to an appendWriter cannot fail
+	w.Write(payload)
+	w.Close()
+	w.Reset(nil) // do not keep out alive
+	pool.Put(w)
+	return out.buf, len(out.buf) < len(payload)
+}
+
+// decompress returns the decompressed payload of an envelope compressed with c and
+// the preset dictionary dict, which is nil if there is none. It fails with
+// ErrTooLarge if the payload decompresses to more than max bytes, unless max is -1.
+func decompress(c Compression, dict, payload []byte, max int) ([]byte, error) {
+	src := bytes.NewReader(payload)
+	var r io.ReadCloser
+	var err error
+	switch c {
+	case Flate:
+		r = flate.NewReaderDict(src, dict)
+	case Gzip:
+		r, err = gzip.NewReader(src)
+	case Zlib:
+		r, err = zlib.NewReaderDict(src, dict)
+	}
+	var buf []byte
+	if err == nil && max >= 0 {
+		// read one byte more than allowed to tell a payload of max bytes from a longer one
+		buf, err = io.ReadAll(io.LimitReader(r, int64(max)+1))
+		if err == nil && len(buf) > max {
+			return nil, fmt.Errorf("%w: %v payload longer than %d bytes", ErrTooLarge, c, max)
+		}
+	} else if err == nil {
+		buf, err = io.ReadAll(r)
+	}
+	if err == nil {
+		err = r.Close()
+	}
+	if err == io.EOF { // the header of gzip or zlib was cut short
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("gotiny: decompressing %v payload: %w", c, err)
+	}
+	return buf, nil
+}
@@ -0,0 +1,183 @@
+package gotiny
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type compressTyp struct {
+	Names []string
+	Data  []byte
+}
+
+func repetitive() compressTyp {
+	v := compressTyp{Data: bytes.Repeat([]byte("abc"), 100)}
+	for i := 0; i < 50; i++ {
+		v.Names = append(v.Names, "name-"+strings.Repeat("x", i%5))
+	}
+	return v
+}
+
+func TestCompression(t *testing.T) {
+	src := repetitive()
+	plain := Marshal(&src)
+	for _, c := range []Compression{NoCompression, Flate, Gzip, Zlib} {
+		buf := MarshalCompressed(c, &src)
+		h, err := ReadHeader(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.Compression != c {
+			t.Errorf("%v: header says %v", c, h.Compression)
+		}
+		if c != NoCompression && len(buf) >= len(plain)/2 {
+			t.Errorf("%v: compressed %d bytes to %d", c, len(plain), len(buf))
+		}
+		var dst compressTyp
+		if n, err := UnmarshalCompressed(buf, &dst); err != nil || n != len(buf) || !reflect.DeepEqual(dst, src) {
+			t.Errorf("%v: decoded %d of %d bytes, %v", c, n, len(buf), err)
+		}
+
+		bad := append([]byte(nil), buf...)
+		bad[len(bad)-len(bad)/4] ^= 0xff
+		if _, err := UnmarshalCompressed(bad, &dst); c != NoCompression && err == nil {
+			t.Errorf("%v: corrupt payload decoded without error", c)
+		}
+	}
+}
+
+func TestCompressThreshold(t *testing.T) {
+	small := "short"
+	if h, _ := ReadHeader(MarshalCompressed(Gzip, &small)); h.Compression != NoCompression {
+		t.Errorf("payload below the threshold compressed with %v", h.Compression)
+	}
+	opts := EnvelopeOptions{Compression: Flate, CompressThreshold: 1, CompressionLevel: 9}
+	long := strings.Repeat("short", 10)
+	if h, _ := ReadHeader(MarshalEnvelope(opts, &long)); h.Compression != Flate {
+		t.Errorf("payload above the threshold compressed with %v", h.Compression)
+	}
+
+	random := make([]byte, 1000)
+	rand.New(rand.NewSource(1)).Read(random)
+	buf := MarshalEnvelope(opts, &random)
+	if h, _ := ReadHeader(buf); h.Compression != NoCompression {
+		t.Errorf("incompressible payload stored compressed with %v", h.Compression)
+	}
+	var dst []byte
+	if _, err := UnmarshalEnvelope(buf, &dst); err != nil || !bytes.Equal(dst, random) {
+		t.Errorf("decoded %d bytes, %v", len(dst), err)
+	}
+}
+
+func TestEnvelopeStream(t *testing.T) {
+	src := repetitive()
+	var stream bytes.Buffer
+	w := NewEnvelopeWriter(&stream, EnvelopeOptions{Compression: Zlib, Fingerprint: true})
+	for i := 0; i < 3; i++ {
+		src.Names[0] = strings.Repeat("y", i)
+		if err := w.Encode(&src, &i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := NewEnvelopeWriter(&stream, EnvelopeOptions{}).Encode(ptr("last")); err != nil {
+		t.Fatal(err)
+	}
+	whole := stream.Bytes()
+
+	r := NewEnvelopeReader(bytes.NewReader(whole))
+	var dsts []compressTyp
+	for i := 0; i < 3; i++ {
+		var dst compressTyp
+		var n int
+		if err := r.Decode(&dst, &n); err != nil || n != i || dst.Names[0] != strings.Repeat("y", i) {
+			t.Fatalf("envelope %d: decoded %d, %q, %v", i, n, dst.Names[0], err)
+		}
+		dsts = append(dsts, dst)
+	}
+	if dsts[0].Names[0] != "" {
+		t.Error("decoding an envelope changed the values decoded from the previous one")
+	}
+	var last string
+	if err := r.Decode(&last); err != nil || last != "last" {
+		t.Errorf("decoded %q, %v", last, err)
+	}
+	if err := r.Decode(&last); err != io.EOF {
+		t.Errorf("at the end: got error %v, want io.EOF", err)
+	}
+
+	r = NewEnvelopeReader(bytes.NewReader(whole[:len(whole)-2]))
+	var err error
+	for err == nil {
+		err = r.Decode(&compressTyp{}, new(int))
+	}
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("truncated stream: got error %v", err)
+	}
+}
+
+// TestEnvelopeStreamLive checks that Decode returns an envelope as soon as the
+// stream holds it, even if it is shorter than the longest header.
+func TestEnvelopeStreamLive(t *testing.T) {
+	for _, opts := range []EnvelopeOptions{{}, {Fingerprint: true, Checksum: true}} {
+		pr, pw := io.Pipe()
+		w := NewEnvelopeWriter(pw, opts)
+		r := NewEnvelopeReader(pr)
+		for i := 0; i < 3; i++ {
+			go w.Encode(&i)
+			done := make(chan error, 1)
+			var n int
+			go func() { done <- r.Decode(&n) }()
+			select {
+			case err := <-done:
+				if err != nil || n != i {
+					t.Fatalf("%+v: envelope %d: decoded %d, %v", opts, i, n, err)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatalf("%+v: envelope %d: Decode waits for more bytes", opts, i)
+			}
+		}
+		pw.Close()
+	}
+}
+
+func TestDecompressLimit(t *testing.T) {
+	src := make([]byte, 1<<20)
+	buf := MarshalCompressed(Flate, &src)
+	if len(buf) > 4<<10 {
+		t.Fatalf("compressed to %d bytes", len(buf))
+	}
+	plain := len(Marshal(&src))
+	for _, c := range []struct {
+		max int
+		ok  bool
+	}{{0, true}, {-1, true}, {plain, true}, {plain - 1, false}, {1000, false}} {
+		var dst []byte
+		_, err := UnmarshalEnvelopeWith(DecodeOptions{MaxDecompressedLen: c.max}, buf, &dst)
+		if c.ok && (err != nil || len(dst) != len(src)) {
+			t.Errorf("limit %d: decoded %d bytes, %v", c.max, len(dst), err)
+		} else if !c.ok && !errors.Is(err, ErrTooLarge) {
+			t.Errorf("limit %d: got error %v, want ErrTooLarge", c.max, err)
+		}
+
+		r := NewEnvelopeReader(bytes.NewReader(buf))
+		r.SetDecodeOptions(DecodeOptions{MaxDecompressedLen: c.max})
+		if err := r.Decode(&dst); c.ok != (err == nil) || !c.ok && !errors.Is(err, ErrTooLarge) {
+			t.Errorf("limit %d in a stream: got error %v", c.max, err)
+		}
+	}
+
+	if testing.Short() {
+		return
+	}
+	src = make([]byte, DefaultMaxDecompressedLen)
+	var dst []byte
+	if _, err := UnmarshalEnvelope(MarshalCompressed(Gzip, &src), &dst); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("beyond the default limit: got error %v, want ErrTooLarge", err)
+	}
+}
@@ -0,0 +1,60 @@
+package gotiny
+
+import (
+	"bytes"
+	"testing"
+)
+
+type copyModeTyp struct {
+	B []byte
+	S string
+	R Raw[string]
+}
+
+func TestCopyMode(t *testing.T) {
+	src := copyModeTyp{B: []byte("bytes"), S: "string"}
+	src.R.Set("raw")
+	tests := []struct {
+		mode                     CopyMode
+		aliasBytes, aliasStrings bool
+	}{
+		{AliasBytes, true, false},
+		{CopyAll, false, false},
+		{AliasBytesAndStrings, true, true},
+	}
+	for _, tt := range tests {
+		buf := Marshal(&src)
+		d := NewDecoderWithPtr(&copyModeTyp{})
+		d.SetCopyMode(tt.mode)
+		var dst copyModeTyp
+		if n := d.Decode(buf, &dst); n != len(buf) {
+			t.Fatalf("mode %d: read %d of %d bytes", tt.mode, n, len(buf))
+		}
+		Assert(t, buf, src, dst)
+
+		raw := append([]byte(nil), dst.R.Bytes()...)
+		for i := range buf {
+			buf[i] = 'x'
+		}
+		if got := !bytes.Equal(dst.B, src.B); got != tt.aliasBytes {
+			t.Errorf("mode %d: []byte aliases the buffer: %v", tt.mode, got)
+		}
+		if got := !bytes.Equal(dst.R.Bytes(), raw); got != tt.aliasBytes {
+			t.Errorf("mode %d: Raw aliases the buffer: %v", tt.mode, got)
+		}
+		if got := dst.S != src.S; got != tt.aliasStrings {
+			t.Errorf("mode %d: string aliases the buffer: %v", tt.mode, got)
+		}
+	}
+}
+
+func TestAliasedBytesCapacity(t *testing.T) {
+	src := [][]byte{[]byte("ab"), []byte("cd")}
+	buf := Marshal(&src)
+	var dst [][]byte
+	Unmarshal(buf, &dst)
+	_ = append(dst[0], "zz"...)
+	if string(dst[1]) != "cd" {
+		t.Fatal("appending to a decoded []byte overwrote the buffer")
+	}
+}
@@ -61,13 +61,14 @@ var (
 		reflect.Complex128: decComplex128,
 		reflect.String:     decString,
 	}
-	decLock sync.RWMutex
+	decLock sync.Mutex
+	decSnap snapshot[reflect.Type, decEng]
 )
 
 // getDecEngine retrieves or builds a decoding engine for the given reflect.Type.
-// It first attempts to retrieve the engine from a cache using a read lock.
-// If the engine is not found in the cache, it acquires a write lock and builds the engine.
-// The function returns the decoding engine for the specified type.
+// It first looks the engine up in the latest snapshot of the cache, without locking.
+// If the engine is not found there, it acquires the lock, builds the engine and stores
+// a new snapshot of the cache. The function returns the decoding engine for the specified type.
 //
 // Parameters:
 //
@@ -77,18 +78,54 @@ var (
 //
 //	decEng - The decoding engine associated with the specified reflect.Type.
 func getDecEngine(reflectType reflect.Type) decEng {
-	decLock.RLock()
-	engine := rt2decEng[reflectType]
-	decLock.RUnlock()
-	if engine != nil {
+	if engine := decSnap.load()[reflectType]; engine != nil {
 		return engine
 	}
 	decLock.Lock()
+	defer decLock.Unlock()
+	var engine decEng
 	buildDecEngine(reflectType, &engine)
-	decLock.Unlock()
+	decSnap.store(rt2decEng)
 	return engine
 }
 
+// ifaceDec holds what decoding an interface value with a given dynamic type
+// takes: the type and its engine.
+type ifaceDec struct {
+	rt  reflect.Type
+	eng decEng
+}
+
+var (
+	// name2ifaceDec caches the ifaceDec of the dynamic types of decoded interface values
+	// by the names they are encoded with, so that decoding such a value takes a single
+	// lookup in a snapshot.
+	name2ifaceDec = map[string]ifaceDec{}
+	ifaceDecLock  sync.Mutex
+	ifaceDecSnap  snapshot[string, ifaceDec]
+)
+
+// decIfaceType decodes the name of the dynamic type of an interface value
+// and returns the ifaceDec of that type.
+func (d *Decoder) decIfaceType() ifaceDec {
+	l := d.decLength()
+	name := d.buf[d.index : d.index+l]
+	d.index += l
+	if id, has := ifaceDecSnap.load()[string(name)]; has {
+		return id
+	}
+	rt, has := name2typeSnap.load()[string(name)]
+	if !has {
+		panic("unknown typ:" + string(name))
+	}
+	id := ifaceDec{rt: rt, eng: getDecEngine(rt)}
+	ifaceDecLock.Lock()
+	defer ifaceDecLock.Unlock()
+	name2ifaceDec[string(name)] = id
+	ifaceDecSnap.store(name2ifaceDec)
+	return id
+}
+
 // buildDecEngine constructs a decoding engine for a given reflect.Type and stores it in engPtr.
 // It first checks if the engine already exists in the rt2decEng map. If it does, it assigns the
 // existing engine to engPtr and returns. If not, it attempts to implement other serializers
@@ -124,6 +161,28 @@ func buildDecEngine(reflectType reflect.Type, engPtr *decEng) {
 		return
 	}
 
+	if isPOD(reflectType) {
+		engine = buildPODDecEngine(reflectType)
+		rt2decEng[reflectType] = engine
+		*engPtr = engine
+		return
+	}
+
+	if et := rawElem(reflectType); et != nil {
+		sEng := getSkipEngine(et)
+		engine = func(d *Decoder, p unsafe.Pointer) {
+			// the encoding of a Raw stands on its own and is that of Marshal,
+			// so find its end with a decoder of its own and the default settings
+			rd := Decoder{buf: d.buf, index: d.index}
+			sEng(&rd)
+			*(*[]byte)(p) = d.bytes(d.index, rd.index)
+			d.index = rd.index
+		}
+		rt2decEng[reflectType] = engine
+		*engPtr = engine
+		return
+	}
+
 	kind := reflectType.Kind()
 	var encodingEngine decEng
 	switch kind {
@@ -144,6 +203,10 @@ func buildDecEngine(reflectType reflect.Type, engPtr *decEng) {
 	case reflect.Array:
 		l, elementType := reflectType.Len(), reflectType.Elem()
 		size := elementType.Size()
+		if b := getBulkEngine(elementType); b != nil {
+			engine = func(d *Decoder, p unsafe.Pointer) { b.dec(d, p, l) }
+			break
+		}
 		defer buildDecEngine(elementType, &encodingEngine)
 		engine = func(d *Decoder, p unsafe.Pointer) {
 			for i := 0; i < l; i++ {
@@ -153,6 +216,10 @@ func buildDecEngine(reflectType reflect.Type, engPtr *decEng) {
 	case reflect.Slice:
 		elementType := reflectType.Elem()
 		size := elementType.Size()
+		if b := getBulkEngine(elementType); b != nil {
+			engine = bulkSliceDecEngine(reflectType, b)
+			break
+		}
 		defer buildDecEngine(elementType, &encodingEngine)
 		engine = func(d *Decoder, p unsafe.Pointer) {
 			header := (*sliceHeader)(p)
@@ -210,22 +277,13 @@ func buildDecEngine(reflectType reflect.Type, engPtr *decEng) {
 			}
 		}
 	case reflect.Interface:
+		if reflectType.NumMethod() == 0 {
+			engine = decEface
+			break
+		}
 		engine = func(d *Decoder, p unsafe.Pointer) {
 			if d.decIsNotNil() {
-				var name string
-				decString(d, unsafe.Pointer(&name))
-				elementType, has := name2type[name]
-				if !has {
-					panic("unknown typ:" + name)
-				}
-				v := reflect.NewAt(reflectType, p).Elem()
-				if v.IsNil() || v.Elem().Type() != elementType {
-					ev := reflect.New(elementType).Elem()
-					getDecEngine(elementType)(d, getUnsafePointer(ev))
-					v.Set(ev)
-				} else {
-					getDecEngine(elementType)(d, getUnsafePointer(v.Elem()))
-				}
+				d.decIface(reflect.NewAt(reflectType, p).Elem())
 			} else if !isNil(p) {
 				*(*unsafe.Pointer)(p) = nil
 			}
@@ -235,6 +293,96 @@ func buildDecEngine(reflectType reflect.Type, engPtr *decEng) {
 	default:
 		engine = decEngines[kind]
 	}
+	if isNestedSerializer(reflectType) {
+		engine = nestedDecEngine(reflectType, engine)
+	}
 	rt2decEng[reflectType] = engine
 	*engPtr = engine
 }
+
+var (
+	// rt2maskedDecEng caches the engines that decode the output of EncodeMasked.
+	// Since the encoding records which fields are present, they only depend on the type.
+	rt2maskedDecEng = map[reflect.Type]decEng{}
+	maskedDecLock   sync.Mutex
+	maskedDecSnap   snapshot[reflect.Type, decEng]
+)
+
+// getMaskedDecEngine retrieves or builds the masked decoding engine for the given reflect.Type.
+func getMaskedDecEngine(rt reflect.Type) decEng {
+	if engine := maskedDecSnap.load()[rt]; engine != nil {
+		return engine
+	}
+	maskedDecLock.Lock()
+	defer maskedDecLock.Unlock()
+	var engine decEng
+	buildMaskedDecEngine(rt, &engine)
+	maskedDecSnap.store(rt2maskedDecEng)
+	return engine
+}
+
+// buildMaskedDecEngine constructs the engine that decodes values of rt encoded by
+// buildMaskedEncEngine, leaving the fields that are not present untouched.
+func buildMaskedDecEngine(rt reflect.Type, engPtr *decEng) {
+	engine := rt2maskedDecEng[rt]
+	if engine != nil {
+		*engPtr = engine
+		return
+	}
+	if !isMaskable(rt) {
+		panic("gotiny: cannot select fields of " + rt.String())
+	}
+
+	if rt.Kind() == reflect.Ptr {
+		et := rt.Elem()
+		var eEng decEng
+		defer buildMaskedDecEngine(et, &eEng)
+		engine = func(d *Decoder, p unsafe.Pointer) {
+			if d.decIsNotNil() {
+				if isNil(p) {
+					*(*unsafe.Pointer)(p) = reflect.New(et).UnsafePointer()
+				}
+				eEng(d, *(*unsafe.Pointer)(p))
+			} else if !isNil(p) {
+				*(*unsafe.Pointer)(p) = nil
+			}
+		}
+	} else {
+		fields := getDirectFields(rt)
+		nf := len(fields)
+		fEngines := make([]decEng, nf) // engines for fields encoded as a whole
+		pEngines := make([]decEng, nf) // engines for fields with a mask of their own
+		for i := 0; i < nf; i++ {
+			fEngines[i] = getDecEngine(fields[i].Type)
+		}
+		defer func() {
+			for i := 0; i < nf; i++ {
+				if isMaskable(fields[i].Type) {
+					buildMaskedDecEngine(fields[i].Type, &pEngines[i])
+				}
+			}
+		}()
+		engine = func(d *Decoder, p unsafe.Pointer) {
+			engines := make([]decEng, nf)
+			for i := 0; i < nf; i++ {
+				if d.decBool() {
+					if d.decBool() {
+						engines[i] = pEngines[i]
+						if engines[i] == nil {
+							panic("gotiny: invalid masked encoding of " + rt.String())
+						}
+					} else {
+						engines[i] = fEngines[i]
+					}
+				}
+			}
+			for i := 0; i < nf; i++ {
+				if engines[i] != nil {
+					engines[i](d, unsafe.Add(p, fields[i].Offset))
+				}
+			}
+		}
+	}
+	rt2maskedDecEng[rt] = engine
+	*engPtr = engine
+}
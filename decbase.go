@@ -1,6 +1,8 @@
 package gotiny
 
 import (
+	"math"
+	"reflect"
 	"time"
 	"unsafe"
 )
@@ -133,47 +135,127 @@ func (d *Decoder) decUint32() uint32 {
 	return x - (1<<7 + 1<<14 + 1<<21 + 1<<28)
 }
 
-func (d *Decoder) decLength() int    { return int(d.decUint32()) }
+// decInt, decUint and decUintptr decode an int, uint or uintptr, which are always
+// encoded with 64 bits. Where the type is narrower, a value that does not fit is
+// handled as the overflow mode of d says. The checks are always false, and compiled
+// away, where the type has 64 bits.
+func (d *Decoder) decInt() int {
+	v := uint64ToInt64(d.decUint64())
+	if int64(int(v)) != v {
+		if d.overflow != OverflowSaturate {
+			panic(overflowError("int", v))
+		}
+		if v < 0 {
+			return math.MinInt
+		}
+		return math.MaxInt
+	}
+	return int(v)
+}
+
+func (d *Decoder) decUint() uint {
+	v := d.decUint64()
+	if uint64(uint(v)) != v {
+		if d.overflow != OverflowSaturate {
+			panic(overflowError("uint", v))
+		}
+		return math.MaxUint
+	}
+	return uint(v)
+}
+
+func (d *Decoder) decUintptr() uintptr {
+	v := d.decUint64()
+	if uint64(uintptr(v)) != v {
+		if d.overflow != OverflowSaturate {
+			panic(overflowError("uintptr", v))
+		}
+		return ^uintptr(0)
+	}
+	return uintptr(v)
+}
+
+// decLength decodes a length encoded by encLength. A length that does not fit in
+// an int, which only a 32-bit platform can see, fails with ErrOverflow whatever the
+// overflow mode, since no value of that length can be built.
+func (d *Decoder) decLength() int {
+	v := d.decUint64()
+	if v > math.MaxInt {
+		panic(overflowError("int", v))
+	}
+	return int(v)
+}
+
 func (d *Decoder) decIsNotNil() bool { return d.decBool() }
 
 func decIgnore(*Decoder, unsafe.Pointer)      {}
 func decBool(d *Decoder, p unsafe.Pointer)    { *(*bool)(p) = d.decBool() }
-func decInt(d *Decoder, p unsafe.Pointer)     { *(*int)(p) = int(uint64ToInt64(d.decUint64())) }
+func decInt(d *Decoder, p unsafe.Pointer)     { *(*int)(p) = d.decInt() }
 func decInt8(d *Decoder, p unsafe.Pointer)    { *(*int8)(p) = int8(d.buf[d.index]); d.index++ }
 func decInt16(d *Decoder, p unsafe.Pointer)   { *(*int16)(p) = uint16ToInt16(d.decUint16()) }
 func decInt32(d *Decoder, p unsafe.Pointer)   { *(*int32)(p) = uint32ToInt32(d.decUint32()) }
 func decInt64(d *Decoder, p unsafe.Pointer)   { *(*int64)(p) = uint64ToInt64(d.decUint64()) }
-func decUint(d *Decoder, p unsafe.Pointer)    { *(*uint)(p) = uint(d.decUint64()) }
+func decUint(d *Decoder, p unsafe.Pointer)    { *(*uint)(p) = d.decUint() }
 func decUint8(d *Decoder, p unsafe.Pointer)   { *(*uint8)(p) = d.buf[d.index]; d.index++ }
 func decUint16(d *Decoder, p unsafe.Pointer)  { *(*uint16)(p) = d.decUint16() }
 func decUint32(d *Decoder, p unsafe.Pointer)  { *(*uint32)(p) = d.decUint32() }
 func decUint64(d *Decoder, p unsafe.Pointer)  { *(*uint64)(p) = d.decUint64() }
-func decUintptr(d *Decoder, p unsafe.Pointer) { *(*uintptr)(p) = uintptr(d.decUint64()) }
+func decUintptr(d *Decoder, p unsafe.Pointer) { *(*uintptr)(p) = d.decUintptr() }
 func decFloat32(d *Decoder, p unsafe.Pointer) { *(*float32)(p) = uint32ToFloat32(d.decUint32()) }
 func decFloat64(d *Decoder, p unsafe.Pointer) { *(*float64)(p) = uint64ToFloat64(d.decUint64()) }
 
 func decTime(d *Decoder, p unsafe.Pointer)      { *(*time.Time)(p) = time.Unix(0, int64(d.decUint64())) }
-func decComplex64(d *Decoder, p unsafe.Pointer) { *(*uint64)(p) = d.decUint64() }
+func decComplex64(d *Decoder, p unsafe.Pointer) { *(*complex64)(p) = uint64ToComplex64(d.decUint64()) }
 func decComplex128(d *Decoder, p unsafe.Pointer) {
-	*(*uint64)(p) = d.decUint64()
-	*(*uint64)(unsafe.Add(p, 8)) = d.decUint64()
+	re := math.Float64frombits(d.decUint64())
+	*(*complex128)(p) = complex(re, math.Float64frombits(d.decUint64()))
 }
 
 // decString decodes a string from the Decoder and stores it at the location
 // pointed to by p. It reads the length of the string as a uint32, then reads
 // the corresponding number of bytes from the Decoder's buffer and converts
-// them to a string. The index of the Decoder is advanced by the length of the
-// string.
+// them to a string, or makes the string refer to them in the
+// AliasBytesAndStrings mode. The index of the Decoder is advanced by the
+// length of the string.
 func decString(d *Decoder, p unsafe.Pointer) {
-	l, val := int(d.decUint32()), (*string)(p)
-	*val = string(d.buf[d.index : d.index+l])
+	l, val := d.decLength(), (*string)(p)
+	if d.mode == AliasBytesAndStrings && l > 0 {
+		*val = unsafe.String(&d.buf[d.index], l)
+	} else {
+		*val = string(d.buf[d.index : d.index+l])
+	}
 	d.index += l
 }
 
+// decEface decodes a value of type any encoded by encEface.
+func decEface(d *Decoder, p unsafe.Pointer) {
+	if d.decIsNotNil() {
+		d.decIface(reflect.NewAt(efaceType, p).Elem())
+	} else if !isNil(p) {
+		*(*unsafe.Pointer)(p) = nil
+	}
+}
+
+// decIface decodes the name of a type and a value of that type into the interface v.
+// If the dynamic value of v already has that type, the value decoded into starts as
+// a copy of it, so that the maps, slices and pointers it refers to are reused. The
+// dynamic value itself is never modified, since other copies of v share it and it
+// may even be in read-only memory.
+func (d *Decoder) decIface(v reflect.Value) {
+	id := d.decIfaceType()
+	ev := reflect.New(id.rt).Elem()
+	if !v.IsNil() && v.Elem().Type() == id.rt {
+		ev.Set(v.Elem())
+	}
+	id.eng(d, ev.Addr().UnsafePointer())
+	v.Set(ev)
+}
+
 // decBytes decodes a byte slice from the Decoder and stores it in the provided pointer.
 // If the decoded value is not nil, it reads the length of the byte slice, extracts the
-// corresponding bytes from the Decoder's buffer, and updates the index. If the decoded
-// value is nil and the pointer is not nil, it sets the byte slice to nil.
+// corresponding bytes from the Decoder's buffer, copying them in the CopyAll mode, and
+// updates the index. If the decoded value is nil and the pointer is not nil, it sets the
+// byte slice to nil.
 //
 // Parameters:
 //   - d: A pointer to the Decoder from which the byte slice is decoded.
@@ -181,10 +263,20 @@ func decString(d *Decoder, p unsafe.Pointer) {
 func decBytes(d *Decoder, p unsafe.Pointer) {
 	bytes := (*[]byte)(p)
 	if d.decIsNotNil() {
-		l := int(d.decUint32())
-		*bytes = d.buf[d.index : d.index+l]
+		l := d.decLength()
+		*bytes = d.bytes(d.index, d.index+l)
 		d.index += l
 	} else if !isNil(p) {
 		*bytes = nil
 	}
 }
+
+// bytes returns buf[start:end], or a copy of it in the CopyAll mode. The capacity of
+// the returned slice is limited to its length, so appending to it never overwrites
+// the rest of the buffer.
+func (d *Decoder) bytes(start, end int) []byte {
+	if d.mode == CopyAll {
+		return append(make([]byte, 0, end-start), d.buf[start:end]...)
+	}
+	return d.buf[start:end:end]
+}
@@ -1,7 +1,13 @@
 package gotiny
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"reflect"
+	"runtime"
+	"strings"
+	"sync"
 	"unsafe"
 )
 
@@ -16,8 +22,73 @@ type Decoder struct {
 	boolPos byte   // index of the next bool to be read in the buffer, i.e., buf[boolPos]
 	boolBit byte   // bit position of the next bool to be read in buf[boolPos]
 
-	engines []decEng // collection of decoders
-	length  int      // number of decoders
+	engines      []decEng     // collection of decoders
+	length       int          // number of decoders
+	mode         CopyMode     // whether decoded []byte and string values share memory with buf
+	overflow     OverflowMode // what to do with an int, uint or uintptr too large for the platform
+	packedFloats bool         // slices and arrays of floats are encoded with fixed width
+}
+
+// CopyMode controls whether decoded []byte and string values, and the bytes held by
+// decoded Raw values, share memory with the buffer they are decoded from.
+type CopyMode uint8
+
+const (
+	// AliasBytes makes decoded []byte and Raw values refer to the decoded buffer,
+	// while strings are copied. The buffer must not be modified for as long as
+	// those values are in use. This is the default mode.
+	AliasBytes CopyMode = iota
+	// CopyAll copies all decoded []byte, string and Raw values, so the buffer
+	// can be reused or modified as soon as decoding returns.
+	CopyAll
+	// AliasBytesAndStrings makes decoded strings refer to the decoded buffer as
+	// well, so decoding copies nothing. Since a string must never change, the
+	// buffer must not be modified for as long as any decoded string is in use,
+	// not even after the []byte values decoded from it are no longer needed.
+	AliasBytesAndStrings
+)
+
+// SetCopyMode sets whether values decoded by d share memory with the decoded buffer.
+// See CopyMode for the guarantees of each mode.
+func (d *Decoder) SetCopyMode(mode CopyMode) {
+	d.mode = mode
+}
+
+// ErrOverflow is wrapped by the errors of decoding an int, uint or uintptr whose
+// encoded value does not fit in the type, or a length that does not fit in an int.
+// These are always encoded with 64 bits, so this happens only on platforms where
+// int has 32 bits, such as 386 and arm, when decoding values encoded on a 64-bit
+// platform.
+var ErrOverflow = errors.New("gotiny: value overflows")
+
+func overflowError(typ string, v any) error {
+	return fmt.Errorf("%w %s: %d", ErrOverflow, typ, v)
+}
+
+// OverflowMode controls how a Decoder handles an int, uint or uintptr whose encoded
+// value does not fit in the type on the decoding platform.
+type OverflowMode uint8
+
+const (
+	// OverflowError makes decoding fail with an error wrapping ErrOverflow:
+	// UnmarshalChecked and DecodeChecked return it, while Unmarshal and Decode,
+	// which cannot return errors, panic with it. This is the default mode.
+	OverflowError OverflowMode = iota
+	// OverflowSaturate stores the largest or smallest value of the type instead,
+	// whichever is closer to the encoded value.
+	OverflowSaturate
+)
+
+// SetOverflowMode sets how d handles an int, uint or uintptr that does not fit in the type.
+// See OverflowMode for the behavior of each mode.
+func (d *Decoder) SetOverflowMode(mode OverflowMode) {
+	d.overflow = mode
+}
+
+// SetPackedFloats sets whether slices and arrays of floats were encoded by an Encoder
+// with packed floats. See Encoder.SetPackedFloats.
+func (d *Decoder) SetPackedFloats(on bool) {
+	d.packedFloats = on
 }
 
 // Unmarshal decodes the provided byte buffer into the given variables.
@@ -31,7 +102,66 @@ type Decoder struct {
 //
 //	The number of bytes read from the buffer.
 func Unmarshal(buf []byte, is ...any) int {
-	return NewDecoderWithPtr(is...).decode(buf, is...)
+	key, ok := getTypesKey(is)
+	if !ok {
+		return NewDecoderWithPtr(is...).decode(buf, is...)
+	}
+	pool := getDecPool(key)
+	d := pool.Get().(*Decoder)
+	n := d.decode(buf, is...)
+	d.buf = nil // do not keep the caller's buffer alive
+	pool.Put(d)
+	return n
+}
+
+// UnmarshalChecked is like Unmarshal, but returns an error instead of panicking if
+// buf cannot be decoded into the given variables: wrapping io.ErrUnexpectedEOF if it
+// is too short or holds a length beyond its end, if a value fails to decode, or,
+// wrapping ErrOverflow, if an int, uint or uintptr does not fit in the type.
+// The variables may have been partially decoded when an error is returned.
+func UnmarshalChecked(buf []byte, is ...any) (int, error) {
+	return unmarshalChecked(buf, false, is)
+}
+
+// unmarshalChecked is UnmarshalChecked with packed floats if packed is true.
+func unmarshalChecked(buf []byte, packed bool, is []any) (int, error) {
+	key, ok := getTypesKey(is)
+	if !ok {
+		d := NewDecoderWithPtr(is...)
+		d.packedFloats = packed
+		return d.DecodeChecked(buf, is...)
+	}
+	pool := getDecPool(key)
+	d := pool.Get().(*Decoder)
+	d.packedFloats = packed
+	n, err := d.DecodeChecked(buf, is...)
+	d.buf = nil            // do not keep the caller's buffer alive
+	d.packedFloats = false // Unmarshal shares the pool
+	pool.Put(d)
+	return n, err
+}
+
+var (
+	decPools    = map[typesKey]*sync.Pool{}
+	decPoolLock sync.Mutex
+	decPoolSnap snapshot[typesKey, *sync.Pool]
+)
+
+// getDecPool returns the pool of decoders for the pointer types in key.
+func getDecPool(key typesKey) *sync.Pool {
+	if pool := decPoolSnap.load()[key]; pool != nil {
+		return pool
+	}
+	ts := key.elemTypes()
+	decPoolLock.Lock()
+	defer decPoolLock.Unlock()
+	pool := decPools[key]
+	if pool == nil {
+		pool = &sync.Pool{New: func() any { return NewDecoderWithType(ts...) }}
+		decPools[key] = pool
+		decPoolSnap.store(decPools)
+	}
+	return pool
 }
 
 // NewDecoderWithPtr creates a new Decoder instance with the provided pointers.
@@ -115,6 +245,38 @@ func (d *Decoder) reset() int {
 	return index
 }
 
+// Decode decodes buf into the values pointed to by is, which must have the types
+// the decoder was created for, and returns the number of bytes read from buf.
+func (d *Decoder) Decode(buf []byte, is ...any) int {
+	return d.decode(buf, is...)
+}
+
+// DecodeChecked is like Decode, but returns an error instead of panicking if buf
+// cannot be decoded. See UnmarshalChecked for the errors it returns.
+func (d *Decoder) DecodeChecked(buf []byte, is ...any) (n int, err error) {
+	buf = buf[:len(buf):len(buf)] // the engines slice up to the capacity
+	defer func() {
+		if x := recover(); x != nil {
+			d.reset()
+			n, err = 0, decodeError(x)
+		}
+	}()
+	return d.decode(buf, is...), nil
+}
+
+// decodeError turns the value a failed decoding panicked with into an error.
+// Reading past the end of the buffer, which its capacity is cut to, is reported
+// as io.ErrUnexpectedEOF.
+func decodeError(x any) error {
+	if err, ok := x.(error); ok && errors.Is(err, ErrOverflow) {
+		return err
+	}
+	if err, ok := x.(runtime.Error); ok && strings.Contains(err.Error(), "out of range") {
+		return fmt.Errorf("gotiny: decoding: %w", io.ErrUnexpectedEOF)
+	}
+	return fmt.Errorf("gotiny: decoding: %v", x)
+}
+
 // Decode takes a byte slice and a variable number of pointers to variables.
 // It decodes the byte slice into the variables.
 // the arguments  must be a pointer type
@@ -139,3 +301,77 @@ func (d *Decoder) decodeValue(buf []byte, vs ...reflect.Value) int {
 	}
 	return d.reset()
 }
+
+// Skip advances past values of the types ts encoded at the start of buf without decoding them,
+// as if they had been decoded with a decoder for ts. Nothing is allocated unless a type
+// implements Serializer, whose encodings can only be measured by decoding them.
+// It returns the number of bytes skipped, so that buf[n:] starts at the next value.
+func (d *Decoder) Skip(buf []byte, ts ...reflect.Type) int {
+	d.buf = buf
+	for _, rt := range ts {
+		getSkipEngine(rt)(d)
+	}
+	return d.reset()
+}
+
+// ErrNilPath is returned by DecodePath when a pointer on the path is nil in the encoded value.
+var ErrNilPath = errors.New("gotiny: nil pointer on path")
+
+// DecodePath decodes a single field of a value of type rt encoded in buf into the value
+// pointed to by out. The field is given by a dot separated path such as "Header.TenantID";
+// pointers to structs on the path are followed. The fields before it are skipped
+// without being decoded, and nothing after it is read. An empty path decodes the whole value.
+//
+// DecodePath returns an error if the path does not exist in rt, if the type of the field
+// is not the element type of out, wrapping ErrNilPath if a pointer on the path is nil,
+// and the errors of UnmarshalChecked if buf cannot be decoded.
+func DecodePath(buf []byte, rt reflect.Type, path string, out any) (err error) {
+	ot := reflect.TypeOf(out)
+	if ot == nil || ot.Kind() != reflect.Ptr {
+		panic("the argument must be a pointer type!")
+	}
+	defer func() {
+		if x := recover(); x != nil {
+			err = decodeError(x)
+		}
+	}()
+	d := Decoder{buf: buf[:len(buf):len(buf)]}
+	for rest := path; rest != ""; {
+		for rt.Kind() == reflect.Ptr {
+			if !d.decIsNotNil() {
+				return fmt.Errorf("%w: %s", ErrNilPath, strings.TrimSuffix(path[:len(path)-len(rest)], "."))
+			}
+			rt = rt.Elem()
+		}
+		if !isMaskable(rt) {
+			return fmt.Errorf("gotiny: %s has no field %s", rt, rest)
+		}
+		fields, _, paths := getFieldPath(rt, 0, "")
+		i := 0
+		for ; i < len(paths); i++ {
+			if rest == paths[i] || strings.HasPrefix(rest, paths[i]+".") || strings.HasPrefix(paths[i], rest+".") {
+				break
+			}
+		}
+		if i == len(paths) {
+			return fmt.Errorf("gotiny: %s has no field %s", rt, rest)
+		}
+		for j := 0; j < i; j++ {
+			getSkipEngine(fields[j])(&d)
+		}
+		if len(rest) < len(paths[i]) {
+			// rest is a struct whose fields were flattened, starting with field i
+			for _, name := range strings.Split(rest, ".") {
+				f, _ := rt.FieldByName(name)
+				rt = f.Type
+			}
+			break
+		}
+		rt, rest = fields[i], strings.TrimPrefix(rest[len(paths[i]):], ".")
+	}
+	if ot.Elem() != rt {
+		return fmt.Errorf("gotiny: cannot decode %s at %q into %s", rt, path, ot.Elem())
+	}
+	getDecEngine(rt)(&d, reflect.ValueOf(out).UnsafePointer())
+	return nil
+}
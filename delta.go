@@ -0,0 +1,401 @@
+package gotiny
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"unsafe"
+)
+
+type (
+	deltaEncEng func(de *deltaEncoder, old, new unsafe.Pointer) // 差量编码器
+	deltaDecEng func(d *Decoder, p unsafe.Pointer)              // 差量解码器
+)
+
+// deltaEncoder is the state of an EncodeDelta call. The embedded Encoder receives
+// the delta, cmp is used to find out which parts of the value changed.
+type deltaEncoder struct {
+	Encoder
+	cmp comparer
+}
+
+var (
+	rt2deltaEncEng = map[reflect.Type]deltaEncEng{}
+	rt2deltaDecEng = map[reflect.Type]deltaDecEng{}
+	deltaEncLock   sync.Mutex
+	deltaEncSnap   snapshot[reflect.Type, deltaEncEng]
+	deltaDecLock   sync.Mutex
+	deltaDecSnap   snapshot[reflect.Type, deltaDecEng]
+)
+
+/*
+EncodeDelta encodes the changes needed to turn *old into *new.
+
+Structs are encoded as a bitmap with one bit per field, as laid out by the
+encoding engines, followed by the deltas of the changed fields. Arrays and
+slices are handled in the same way per element, with the elements that new
+appends encoded in full. Maps record their removed keys and the entries that
+were added or changed, pointers are followed. Any other value is encoded in
+full if it changed.
+*/
+func EncodeDelta[T any](old, new *T) []byte {
+	var de deltaEncoder
+	getDeltaEncEngine(reflect.TypeFor[T]())(&de, unsafe.Pointer(old), unsafe.Pointer(new))
+	return de.buf
+}
+
+// ApplyDelta applies a delta produced by EncodeDelta(old, new) to *base, which
+// should be equal to *old, leaving it equal to *new. Slices and maps of *base are
+// updated in place where possible. It returns the number of bytes read from delta,
+// or an error like UnmarshalChecked if delta is truncated or malformed, in which
+// case *base may have been changed in part.
+func ApplyDelta[T any](base *T, delta []byte) (n int, err error) {
+	defer func() {
+		if x := recover(); x != nil {
+			n, err = 0, decodeError(x)
+		}
+	}()
+	d := Decoder{buf: delta[:len(delta):len(delta)]}
+	getDeltaDecEngine(reflect.TypeFor[T]())(&d, unsafe.Pointer(base))
+	return d.index, nil
+}
+
+// maxChangedOnStack is the number of parts of a value up to which the engines
+// keep the bools that say which of them changed on the stack.
+const maxChangedOnStack = 64
+
+// changedBools returns buf[:n], or a new slice of n bools if buf is too short.
+func changedBools(buf []bool, n int) []bool {
+	if n > len(buf) {
+		return make([]bool, n)
+	}
+	return buf[:n]
+}
+
+// getDeltaEncEngine retrieves or builds a delta encoding engine for the given reflect.Type.
+func getDeltaEncEngine(rt reflect.Type) deltaEncEng {
+	if engine := deltaEncSnap.load()[rt]; engine != nil {
+		return engine
+	}
+	deltaEncLock.Lock()
+	defer deltaEncLock.Unlock()
+	var engine deltaEncEng
+	buildDeltaEncEngine(rt, &engine)
+	deltaEncSnap.store(rt2deltaEncEng)
+	return engine
+}
+
+// getDeltaDecEngine retrieves or builds a delta decoding engine for the given reflect.Type.
+func getDeltaDecEngine(rt reflect.Type) deltaDecEng {
+	if engine := deltaDecSnap.load()[rt]; engine != nil {
+		return engine
+	}
+	deltaDecLock.Lock()
+	defer deltaDecLock.Unlock()
+	var engine deltaDecEng
+	buildDeltaDecEngine(rt, &engine)
+	deltaDecSnap.store(rt2deltaDecEng)
+	return engine
+}
+
+// isDeltaLeaf reports whether values of rt are written in full rather than as a delta.
+func isDeltaLeaf(rt reflect.Type) bool {
+	switch rt.Kind() {
+	case reflect.Ptr, reflect.Array, reflect.Slice, reflect.Map, reflect.Struct:
+		enc, _ := implementOtherSerializer(rt)
+		return enc != nil || rt == reflect.TypeFor[[]byte]()
+	}
+	return true
+}
+
+// buildDeltaEncEngine constructs a delta encoding engine for the given reflect.Type and assigns
+// it to engPtr, building the engines of element types in deferred calls like buildEncEngine.
+func buildDeltaEncEngine(rt reflect.Type, engPtr *deltaEncEng) {
+	engine := rt2deltaEncEng[rt]
+	if engine != nil {
+		*engPtr = engine
+		return
+	}
+	if isDeltaLeaf(rt) {
+		enc := getEncEngine(rt)
+		engine = func(de *deltaEncoder, old, new unsafe.Pointer) { enc(&de.Encoder, new) }
+		rt2deltaEncEng[rt] = engine
+		*engPtr = engine
+		return
+	}
+
+	var eEng deltaEncEng
+	switch rt.Kind() {
+	case reflect.Ptr:
+		et := rt.Elem()
+		enc := getEncEngine(et)
+		defer buildDeltaEncEngine(et, &eEng)
+		engine = func(de *deltaEncoder, old, new unsafe.Pointer) {
+			isNotNil := !isNil(new)
+			de.encIsNotNil(isNotNil)
+			if !isNotNil {
+				return
+			}
+			hasOld := !isNil(old)
+			de.encBool(hasOld)
+			if hasOld {
+				eEng(de, *(*unsafe.Pointer)(old), *(*unsafe.Pointer)(new))
+			} else {
+				enc(&de.Encoder, *(*unsafe.Pointer)(new))
+			}
+		}
+	case reflect.Array:
+		et, l := rt.Elem(), rt.Len()
+		size := int(et.Size())
+		enc := getEncEngine(et)
+		defer buildDeltaEncEngine(et, &eEng)
+		engine = func(de *deltaEncoder, old, new unsafe.Pointer) {
+			var buf [maxChangedOnStack]bool
+			changed := changedBools(buf[:], l)
+			for i := 0; i < l; i++ {
+				changed[i] = !de.cmp.equal(enc, unsafe.Add(old, i*size), unsafe.Add(new, i*size))
+				de.encBool(changed[i])
+			}
+			for i := 0; i < l; i++ {
+				if changed[i] {
+					eEng(de, unsafe.Add(old, i*size), unsafe.Add(new, i*size))
+				}
+			}
+		}
+	case reflect.Slice:
+		et := rt.Elem()
+		size := int(et.Size())
+		enc := getEncEngine(et)
+		defer buildDeltaEncEngine(et, &eEng)
+		engine = func(de *deltaEncoder, old, new unsafe.Pointer) {
+			isNotNil := !isNil(new)
+			de.encIsNotNil(isNotNil)
+			if !isNotNil {
+				return
+			}
+			oh, nh := (*sliceHeader)(old), (*sliceHeader)(new)
+			m := oh.len
+			if nh.len < m {
+				m = nh.len
+			}
+			de.encLength(nh.len)
+			de.encLength(m)
+			var buf [maxChangedOnStack]bool
+			changed := changedBools(buf[:], m)
+			for i := 0; i < m; i++ {
+				changed[i] = !de.cmp.equal(enc, unsafe.Add(oh.data, i*size), unsafe.Add(nh.data, i*size))
+				de.encBool(changed[i])
+			}
+			for i := 0; i < m; i++ {
+				if changed[i] {
+					eEng(de, unsafe.Add(oh.data, i*size), unsafe.Add(nh.data, i*size))
+				}
+			}
+			for i := m; i < nh.len; i++ {
+				enc(&de.Encoder, unsafe.Add(nh.data, i*size))
+			}
+		}
+	case reflect.Map:
+		kEnc, vEnc := getEncEngine(rt.Key()), getEncEngine(rt.Elem())
+		engine = func(de *deltaEncoder, old, new unsafe.Pointer) {
+			isNotNil := !isNil(new)
+			de.encIsNotNil(isNotNil)
+			if !isNotNil {
+				return
+			}
+			ov, nv := reflect.NewAt(rt, old).Elem(), reflect.NewAt(rt, new).Elem()
+			var removed, upserted []reflect.Value
+			iter := ov.MapRange()
+			for iter.Next() {
+				if !nv.MapIndex(iter.Key()).IsValid() {
+					removed = append(removed, iter.Key())
+				}
+			}
+			iter = nv.MapRange()
+			for iter.Next() {
+				k, v := iter.Key(), iter.Value()
+				if ove := ov.MapIndex(k); !ove.IsValid() || !de.cmp.equal(vEnc, getUnsafePointer(ove), getUnsafePointer(v)) {
+					upserted = append(upserted, k, v)
+				}
+			}
+			de.encLength(len(removed))
+			for _, k := range removed {
+				kEnc(&de.Encoder, getUnsafePointer(k))
+			}
+			de.encLength(len(upserted) / 2)
+			for i := 0; i < len(upserted); i += 2 {
+				kEnc(&de.Encoder, getUnsafePointer(upserted[i]))
+				vEnc(&de.Encoder, getUnsafePointer(upserted[i+1]))
+			}
+		}
+	case reflect.Struct:
+		fields, offs := getFieldType(rt, 0)
+		nf := len(fields)
+		encs := make([]encEng, nf)
+		for i := 0; i < nf; i++ {
+			encs[i] = getEncEngine(fields[i])
+		}
+		fEngines := make([]deltaEncEng, nf)
+		defer func() {
+			for i := 0; i < nf; i++ {
+				buildDeltaEncEngine(fields[i], &fEngines[i])
+			}
+		}()
+		engine = func(de *deltaEncoder, old, new unsafe.Pointer) {
+			var buf [maxChangedOnStack]bool
+			changed := changedBools(buf[:], nf)
+			for i := 0; i < nf; i++ {
+				changed[i] = !de.cmp.equal(encs[i], unsafe.Add(old, offs[i]), unsafe.Add(new, offs[i]))
+				de.encBool(changed[i])
+			}
+			for i := 0; i < nf; i++ {
+				if changed[i] {
+					fEngines[i](de, unsafe.Add(old, offs[i]), unsafe.Add(new, offs[i]))
+				}
+			}
+		}
+	}
+	rt2deltaEncEng[rt] = engine
+	*engPtr = engine
+}
+
+// buildDeltaDecEngine constructs the delta decoding engine matching buildDeltaEncEngine
+// for the given reflect.Type and assigns it to engPtr.
+func buildDeltaDecEngine(rt reflect.Type, engPtr *deltaDecEng) {
+	engine := rt2deltaDecEng[rt]
+	if engine != nil {
+		*engPtr = engine
+		return
+	}
+	if isDeltaLeaf(rt) {
+		engine = deltaDecEng(getDecEngine(rt))
+		rt2deltaDecEng[rt] = engine
+		*engPtr = engine
+		return
+	}
+
+	var eEng deltaDecEng
+	switch rt.Kind() {
+	case reflect.Ptr:
+		et := rt.Elem()
+		dec := getDecEngine(et)
+		defer buildDeltaDecEngine(et, &eEng)
+		engine = func(d *Decoder, p unsafe.Pointer) {
+			if !d.decIsNotNil() {
+				*(*unsafe.Pointer)(p) = nil
+				return
+			}
+			hasOld := d.decBool()
+			if isNil(p) {
+				*(*unsafe.Pointer)(p) = reflect.New(et).UnsafePointer()
+			}
+			if hasOld {
+				eEng(d, *(*unsafe.Pointer)(p))
+			} else {
+				dec(d, *(*unsafe.Pointer)(p))
+			}
+		}
+	case reflect.Array:
+		et, l := rt.Elem(), rt.Len()
+		size := int(et.Size())
+		defer buildDeltaDecEngine(et, &eEng)
+		engine = func(d *Decoder, p unsafe.Pointer) {
+			var buf [maxChangedOnStack]bool
+			changed := changedBools(buf[:], l)
+			for i := 0; i < l; i++ {
+				changed[i] = d.decBool()
+			}
+			for i := 0; i < l; i++ {
+				if changed[i] {
+					eEng(d, unsafe.Add(p, i*size))
+				}
+			}
+		}
+	case reflect.Slice:
+		et := rt.Elem()
+		size := int(et.Size())
+		dec := getDecEngine(et)
+		defer buildDeltaDecEngine(et, &eEng)
+		engine = func(d *Decoder, p unsafe.Pointer) {
+			header := (*sliceHeader)(p)
+			if !d.decIsNotNil() {
+				*header = sliceHeader{}
+				return
+			}
+			l, m := d.decLength(), d.decLength()
+			if m > l {
+				panic(fmt.Errorf("gotiny: delta of %d elements of a slice of %d", m, l))
+			}
+			if isNil(p) || header.cap < l {
+				// reflect.Copy, unlike memCopy, copies pointers with write barriers
+				s := reflect.MakeSlice(rt, l, l)
+				reflect.Copy(s, reflect.NewAt(rt, p).Elem())
+				*header = sliceHeader{data: s.UnsafePointer(), len: l, cap: l}
+			} else {
+				header.len = l
+			}
+			var buf [maxChangedOnStack]bool
+			changed := changedBools(buf[:], m)
+			for i := 0; i < m; i++ {
+				changed[i] = d.decBool()
+			}
+			for i := 0; i < m; i++ {
+				if changed[i] {
+					eEng(d, unsafe.Add(header.data, i*size))
+				}
+			}
+			for i := m; i < l; i++ {
+				dec(d, unsafe.Add(header.data, i*size))
+			}
+		}
+	case reflect.Map:
+		kt, vt := rt.Key(), rt.Elem()
+		kDec, vDec := getDecEngine(kt), getDecEngine(vt)
+		engine = func(d *Decoder, p unsafe.Pointer) {
+			if !d.decIsNotNil() {
+				*(*unsafe.Pointer)(p) = nil
+				return
+			}
+			v := reflect.NewAt(rt, p).Elem()
+			if isNil(p) {
+				v.Set(reflect.MakeMap(rt))
+			}
+			key, val := reflect.New(kt).Elem(), reflect.New(vt).Elem()
+			for i, l := 0, d.decLength(); i < l; i++ {
+				kDec(d, unsafe.Pointer(key.UnsafeAddr()))
+				v.SetMapIndex(key, reflect.Value{})
+				key.SetZero()
+			}
+			for i, l := 0, d.decLength(); i < l; i++ {
+				kDec(d, unsafe.Pointer(key.UnsafeAddr()))
+				vDec(d, unsafe.Pointer(val.UnsafeAddr()))
+				v.SetMapIndex(key, val)
+				key.SetZero()
+				val.SetZero()
+			}
+		}
+	case reflect.Struct:
+		fields, offs := getFieldType(rt, 0)
+		nf := len(fields)
+		fEngines := make([]deltaDecEng, nf)
+		defer func() {
+			for i := 0; i < nf; i++ {
+				buildDeltaDecEngine(fields[i], &fEngines[i])
+			}
+		}()
+		engine = func(d *Decoder, p unsafe.Pointer) {
+			var buf [maxChangedOnStack]bool
+			changed := changedBools(buf[:], nf)
+			for i := 0; i < nf; i++ {
+				changed[i] = d.decBool()
+			}
+			for i := 0; i < nf; i++ {
+				if changed[i] {
+					fEngines[i](d, unsafe.Add(p, offs[i]))
+				}
+			}
+		}
+	}
+	rt2deltaDecEng[rt] = engine
+	*engPtr = engine
+}
@@ -0,0 +1,104 @@
+package gotiny
+
+import (
+	"reflect"
+	"testing"
+)
+
+type deltaTyp struct {
+	Name   string
+	Pos    struct{ X, Y float64 }
+	Owner  *tA
+	Items  []diffItem
+	Scores map[string]int
+	Flags  [4]bool
+	Note   string `gotiny:"-"`
+}
+
+func newDeltaTyp() deltaTyp {
+	return deltaTyp{
+		Name:   "player",
+		Items:  []diffItem{{"a", 1}, {"b", 2}, {"c", 3}},
+		Scores: map[string]int{"x": 1, "y": 2},
+	}
+}
+
+func TestDelta(t *testing.T) {
+	old, new := newDeltaTyp(), newDeltaTyp()
+	Clone(&new, &old)
+	new.Pos.Y = 1.5
+	new.Owner = &tA{Name: "bob"}
+	new.Items[1].Price = 5
+	new.Items = append(new.Items, diffItem{"d", 4})
+	delete(new.Scores, "x")
+	new.Scores["y"] = 3
+	new.Scores["z"] = 4
+	new.Flags[2] = true
+
+	var base deltaTyp
+	Clone(&base, &old)
+	delta := EncodeDelta(&old, &new)
+	if n, err := ApplyDelta(&base, delta); err != nil || n != len(delta) {
+		t.Fatalf("read %d bytes of a %d byte delta, %v", n, len(delta), err)
+	}
+	Assert(t, delta, new, base)
+
+	new.Items = new.Items[:1]
+	new.Owner.Siblings = 2
+	new.Scores = nil
+	delta = EncodeDelta(&base, &new)
+	if _, err := ApplyDelta(&base, delta); err != nil {
+		t.Fatal(err)
+	}
+	Assert(t, delta, new, base)
+}
+
+func TestDeltaMalformed(t *testing.T) {
+	// a delta that claims 4 changed elements of a slice of 1
+	backing := [4]int64{}
+	base := backing[:1:1]
+	if _, err := ApplyDelta(&base, []byte{0x1f, 0x01, 0x04, 0x0e, 0x0e, 0x0e, 0x0e}); err == nil {
+		t.Error("more changed elements than the slice holds: no error")
+	}
+	if backing != [4]int64{} {
+		t.Errorf("wrote past the end of the slice: %v", backing)
+	}
+
+	old, new := newDeltaTyp(), newDeltaTyp()
+	new.Items = append(new.Items, diffItem{"d", 4})
+	delta := EncodeDelta(&old, &new)
+	for i := range delta {
+		base := newDeltaTyp()
+		if _, err := ApplyDelta(&base, delta[:i]); err == nil {
+			t.Errorf("delta cut to %d of %d bytes: no error", i, len(delta))
+		}
+	}
+}
+
+func TestDeltaSmall(t *testing.T) {
+	old := newDeltaTyp()
+	old.Items = make([]diffItem, 1000)
+	var new deltaTyp
+	Clone(&new, &old)
+	new.Items[500].Name = "changed"
+	if delta, full := EncodeDelta(&old, &new), Marshal(&new); len(delta) > 200 {
+		t.Fatalf("delta of %d bytes for a single change, full encoding is %d bytes", len(delta), len(full))
+	}
+}
+
+func TestDeltaValues(t *testing.T) {
+	for i, src := range srci {
+		zero, base := reflect.New(typs[i]), reflect.New(typs[i])
+		p := reflect.ValueOf(src).UnsafePointer()
+
+		de := deltaEncoder{}
+		getDeltaEncEngine(typs[i])(&de, zero.UnsafePointer(), p)
+		getDeltaDecEngine(typs[i])(&Decoder{buf: de.buf}, base.UnsafePointer())
+		Assert(t, de.buf, src, base.Interface())
+
+		de = deltaEncoder{}
+		getDeltaEncEngine(typs[i])(&de, p, zero.UnsafePointer())
+		getDeltaDecEngine(typs[i])(&Decoder{buf: de.buf}, base.UnsafePointer())
+		Assert(t, de.buf, zero.Interface(), base.Interface())
+	}
+}
@@ -0,0 +1,155 @@
+package gotiny
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// MaxDictionarySize is the size of the DEFLATE window. Bytes of a preset dictionary
+// further than that from the end of it are never used.
+const MaxDictionarySize = 32 << 10
+
+// dictionary is a registered preset dictionary, with the compressors that use it.
+type dictionary struct {
+	data        []byte
+	compressors compressorPools
+}
+
+var (
+	dictionaries = map[uint32]*dictionary{}
+	dictLock     sync.Mutex
+	dictSnap     snapshot[uint32, *dictionary]
+)
+
+// RegisterDictionary registers the preset dictionary dict under id, so that envelopes
+// can be compressed with it by setting EnvelopeOptions.Dictionary to id, and decoded.
+// Encoder and decoder must register the same dictionary under the same ID; since the
+// ID is all an envelope records of it, a changed dictionary needs a new ID.
+// It panics if id is 0 or is already registered with other data.
+func RegisterDictionary(id uint32, dict []byte) {
+	if id == 0 {
+		panic("gotiny: dictionary ID 0 is reserved")
+	}
+	dictLock.Lock()
+	defer dictLock.Unlock()
+	if d, has := dictionaries[id]; has {
+		if !bytes.Equal(d.data, dict) {
+			panic(fmt.Sprintf("gotiny: registering another dictionary with ID %d", id))
+		}
+		return
+	}
+	dictionaries[id] = &dictionary{data: bytes.Clone(dict)}
+	dictSnap.store(dictionaries)
+}
+
+// getDictionary returns the dictionary registered under id, or nil.
+func getDictionary(id uint32) *dictionary {
+	return dictSnap.load()[id]
+}
+
+// gram is a substring of the samples of BuildDictionary and the number of samples
+// it occurs in.
+type gram struct {
+	s string
+	n int
+}
+
+// dictGramLen is the length of the substrings BuildDictionary counts. Matches
+// shorter than that save little in DEFLATE.
+const dictGramLen = 8
+
+// BuildDictionary builds a preset dictionary of at most size bytes, or
+// MaxDictionarySize if size is not positive, for compressing messages like samples,
+// typically encodings of values of the same type. It counts the samples each
+// substring of dictGramLen bytes occurs in, chains the most common substrings
+// that overlap into segments, and places the most common segments at the end of
+// the dictionary, where DEFLATE refers to them with the shortest distances.
+// Substrings that occur in a single sample are left out, since they are unlikely
+// to occur in other messages.
+//
+// Its memory use grows with the total length of the samples; a few thousand
+// samples of small messages are enough.
+func BuildDictionary(samples [][]byte, size int) []byte {
+	if size <= 0 || size > MaxDictionarySize {
+		size = MaxDictionarySize
+	}
+	counts := map[string]int{} // number of samples each substring occurs in
+	seen := map[string]bool{}
+	for _, s := range samples {
+		clear(seen)
+		for i := 0; i+dictGramLen <= len(s); i++ {
+			g := string(s[i : i+dictGramLen])
+			if !seen[g] {
+				seen[g] = true
+				counts[g]++
+			}
+		}
+	}
+	grams := make([]gram, 0, len(counts))
+	for s, n := range counts {
+		if n > 1 {
+			grams = append(grams, gram{s, n})
+		}
+	}
+	sort.Slice(grams, func(i, j int) bool {
+		if grams[i].n != grams[j].n {
+			return grams[i].n > grams[j].n
+		}
+		return grams[i].s < grams[j].s
+	})
+
+	// next and prev map the first and the last dictGramLen-1 bytes of the substrings
+	// to the most common substring starting or ending with them
+	next, prev := map[string]gram{}, map[string]gram{}
+	for _, g := range grams { // most common first, so the first one seen is kept
+		if _, ok := next[g.s[:dictGramLen-1]]; !ok {
+			next[g.s[:dictGramLen-1]] = g
+		}
+		if _, ok := prev[g.s[1:]]; !ok {
+			prev[g.s[1:]] = g
+		}
+	}
+
+	// Each segment starts with the most common substring not used yet, and is
+	// extended on both sides, one byte at a time, with the most common substring
+	// that overlaps it, as long as that occurs in at least half as many samples.
+	var segments []string
+	used := map[string]bool{}
+	total := 0
+	for _, seed := range grams {
+		if used[seed.s] || size-total < dictGramLen {
+			continue
+		}
+		used[seed.s] = true
+		seg := seed.s
+		for {
+			g, ok := next[seg[len(seg)-dictGramLen+1:]]
+			if !ok || used[g.s] || 2*g.n < seed.n {
+				break
+			}
+			used[g.s] = true
+			seg += g.s[dictGramLen-1:]
+		}
+		for {
+			g, ok := prev[seg[:dictGramLen-1]]
+			if !ok || used[g.s] || 2*g.n < seed.n {
+				break
+			}
+			used[g.s] = true
+			seg = g.s[:1] + seg
+		}
+		for i := 0; i+dictGramLen <= len(seg); i++ {
+			used[seg[i:i+dictGramLen]] = true
+		}
+		seg = seg[:min(len(seg), size-total)]
+		segments = append(segments, seg)
+		total += len(seg)
+	}
+	dict := make([]byte, 0, total)
+	for i := len(segments) - 1; i >= 0; i-- {
+		dict = append(dict, segments[i]...)
+	}
+	return dict
+}
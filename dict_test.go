@@ -0,0 +1,91 @@
+package gotiny
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+type dictTyp struct {
+	Method string
+	Tenant string
+	ID     uint64
+	Tags   []string
+}
+
+func dictSample(i int) dictTyp {
+	return dictTyp{Method: "orders.v1.Orders/Get", Tenant: "tenant-" + strconv.Itoa(i%7), ID: uint64(i) * 7919,
+		Tags: []string{"region=eu-west-1", "tier=" + strconv.Itoa(i%3)}}
+}
+
+func TestBuildDictionary(t *testing.T) {
+	var samples [][]byte
+	for i := 0; i < 100; i++ {
+		v := dictSample(i)
+		samples = append(samples, Marshal(&v))
+	}
+	dict := BuildDictionary(samples, 0)
+	if len(dict) == 0 || len(dict) > MaxDictionarySize {
+		t.Fatalf("dictionary of %d bytes", len(dict))
+	}
+	for _, s := range []string{"orders.v1.Orders/Get", "region=eu-west-1"} {
+		if !bytes.Contains(dict, []byte(s)) {
+			t.Errorf("dictionary %q does not contain %q", dict, s)
+		}
+	}
+	if again := BuildDictionary(samples, 0); !bytes.Equal(again, dict) {
+		t.Error("the dictionary is not deterministic")
+	}
+	if small := BuildDictionary(samples, 16); len(small) > 16 {
+		t.Errorf("dictionary of %d bytes, want at most 16", len(small))
+	}
+	if d := BuildDictionary(samples[:1], 0); len(d) != 0 {
+		t.Errorf("dictionary %q from a single sample", d)
+	}
+
+	const id = 0x474f
+	RegisterDictionary(id, dict)
+	RegisterDictionary(id, dict) // the same data again is allowed
+	src := dictSample(1000)
+	plain := MarshalEnvelope(EnvelopeOptions{Compression: Flate, CompressThreshold: 1}, &src)
+	for _, c := range []Compression{Flate, Zlib} {
+		buf := MarshalEnvelope(EnvelopeOptions{Compression: c, Dictionary: id}, &src)
+		h, err := ReadHeader(buf)
+		if err != nil || h.Dictionary != id || h.Compression != c {
+			t.Fatalf("%v: header %+v, %v", c, h, err)
+		}
+		if len(buf) >= len(plain)*2/3 {
+			t.Errorf("%v: %d bytes with the dictionary, %d without", c, len(buf), len(plain))
+		}
+		var dst dictTyp
+		if n, err := UnmarshalEnvelope(buf, &dst); err != nil || n != len(buf) || !reflect.DeepEqual(dst, src) {
+			t.Errorf("%v: decoded %d of %d bytes, %v", c, n, len(buf), err)
+		}
+
+		buf[6] ^= 0xff // the dictionary ID, after the magic, version and flags
+		if _, err := UnmarshalEnvelope(buf, &dst); !errors.Is(err, ErrDictionary) {
+			t.Errorf("%v: unknown dictionary: got error %v", c, err)
+		}
+	}
+}
+
+func TestRegisterDictionaryPanics(t *testing.T) {
+	RegisterDictionary(0x4750, []byte("one"))
+	for name, f := range map[string]func(){
+		"zero ID":    func() { RegisterDictionary(0, []byte("x")) },
+		"other data": func() { RegisterDictionary(0x4750, []byte("two")) },
+		"gzip":       func() { MarshalEnvelope(EnvelopeOptions{Compression: Gzip, Dictionary: 0x4750}, ptr("x")) },
+		"unknown":    func() { MarshalEnvelope(EnvelopeOptions{Compression: Flate, Dictionary: 0x4751}, ptr("x")) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: no panic", name)
+				}
+			}()
+			f()
+		}()
+	}
+}
@@ -0,0 +1,297 @@
+package gotiny
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"unsafe"
+)
+
+// Difference describes one place where the two values passed to Diff differ.
+type Difference struct {
+	Path string // location of the value, e.g. `Items[2].Name` or `Tags["a"]`; empty for the whole value
+	Old  any    // the value in a, or nil if a has no value at Path
+	New  any    // the value in b, or nil if b has no value at Path
+}
+
+type diffEng func(df *differ, a, b unsafe.Pointer) // 比较器
+
+// comparer compares values by their canonical encoding.
+type comparer struct {
+	a, b Encoder
+}
+
+// equal reports whether the values pointed to by a and b have the same canonical encoding
+// under the encoding engine eng.
+func (c *comparer) equal(eng encEng, a, b unsafe.Pointer) bool {
+	c.a = Encoder{buf: c.a.buf[:0], canonical: true}
+	c.b = Encoder{buf: c.b.buf[:0], canonical: true}
+	eng(&c.a, a)
+	eng(&c.b, b)
+	return bytes.Equal(c.a.buf, c.b.buf)
+}
+
+// differ holds the state of a Diff call.
+type differ struct {
+	comparer
+	path  []byte // path of the value being compared
+	diffs []Difference
+}
+
+// add records a difference at the current path. A nil pointer stands for a missing value.
+func (df *differ) add(rt reflect.Type, a, b unsafe.Pointer) {
+	d := Difference{Path: string(df.path)}
+	if a != nil {
+		d.Old = reflect.NewAt(rt, a).Elem().Interface()
+	}
+	if b != nil {
+		d.New = reflect.NewAt(rt, b).Elem().Interface()
+	}
+	df.diffs = append(df.diffs, d)
+}
+
+var (
+	rt2diffEng = map[reflect.Type]diffEng{
+		reflect.TypeFor[struct{}](): diffIgnore,
+		reflect.TypeOf(nil):         diffIgnore,
+	}
+	diffLock sync.Mutex
+	diffSnap snapshot[reflect.Type, diffEng]
+)
+
+func diffIgnore(*differ, unsafe.Pointer, unsafe.Pointer) {}
+
+// Equal reports whether a and b have the same type and encode to the same bytes,
+// with maps compared regardless of their iteration order. Like the encoding,
+// it ignores fields tagged with `gotiny:"-"`.
+func Equal(a, b any) bool {
+	rt := reflect.TypeOf(a)
+	if rt != reflect.TypeOf(b) {
+		return false
+	}
+	var c comparer
+	return c.equal(getEncEngine(rt), getUnsafePointer(reflect.ValueOf(a)), getUnsafePointer(reflect.ValueOf(b)))
+}
+
+// Diff returns the differences between a and b, using the same notion of equality
+// as Equal. Structs, pointers, arrays, slices, maps and interfaces holding values of
+// the same type are compared element by element; any other differing value is
+// reported as a whole. If a and b have different types, Diff reports a single
+// Difference with an empty path.
+func Diff(a, b any) []Difference {
+	rt := reflect.TypeOf(a)
+	if rt != reflect.TypeOf(b) {
+		return []Difference{{Old: a, New: b}}
+	}
+	var df differ
+	getDiffEngine(rt)(&df, getUnsafePointer(reflect.ValueOf(a)), getUnsafePointer(reflect.ValueOf(b)))
+	return df.diffs
+}
+
+// getDiffEngine retrieves or builds a diff engine for the given reflect.Type,
+// in the same way getEncEngine does for encoding engines.
+func getDiffEngine(rt reflect.Type) diffEng {
+	if engine := diffSnap.load()[rt]; engine != nil {
+		return engine
+	}
+	diffLock.Lock()
+	defer diffLock.Unlock()
+	var engine diffEng
+	buildDiffEngine(rt, &engine)
+	diffSnap.store(rt2diffEng)
+	return engine
+}
+
+// buildDiffEngine constructs a diff engine for the given reflect.Type and assigns it to engPtr.
+// The engines walk the same values the encoding engines do: composite types are walked
+// element by element, while basic types, []byte and types implementing one of the serializer
+// interfaces are compared as a whole by their encoding.
+func buildDiffEngine(rt reflect.Type, engPtr *diffEng) {
+	engine := rt2diffEng[rt]
+	if engine != nil {
+		*engPtr = engine
+		return
+	}
+
+	kind := rt.Kind()
+	enc, _ := implementOtherSerializer(rt)
+	var eEng diffEng
+	switch {
+	case enc != nil, rt == reflect.TypeFor[[]byte]():
+		engine = leafDiffEngine(rt)
+	case kind == reflect.Ptr:
+		defer buildDiffEngine(rt.Elem(), &eEng)
+		engine = func(df *differ, a, b unsafe.Pointer) {
+			switch aNil, bNil := isNil(a), isNil(b); {
+			case aNil && bNil:
+			case aNil || bNil:
+				df.add(rt, a, b)
+			default:
+				eEng(df, *(*unsafe.Pointer)(a), *(*unsafe.Pointer)(b))
+			}
+		}
+	case kind == reflect.Array:
+		et, l := rt.Elem(), rt.Len()
+		size := int(et.Size())
+		defer buildDiffEngine(et, &eEng)
+		engine = func(df *differ, a, b unsafe.Pointer) {
+			for i := 0; i < l; i++ {
+				n := len(df.path)
+				df.path = strconv.AppendInt(append(df.path, '['), int64(i), 10)
+				df.path = append(df.path, ']')
+				eEng(df, unsafe.Add(a, i*size), unsafe.Add(b, i*size))
+				df.path = df.path[:n]
+			}
+		}
+	case kind == reflect.Slice:
+		et := rt.Elem()
+		size := int(et.Size())
+		defer buildDiffEngine(et, &eEng)
+		engine = func(df *differ, a, b unsafe.Pointer) {
+			if aNil, bNil := isNil(a), isNil(b); aNil || bNil {
+				if aNil != bNil {
+					df.add(rt, a, b)
+				}
+				return
+			}
+			ah, bh := (*sliceHeader)(a), (*sliceHeader)(b)
+			for i := 0; i < ah.len || i < bh.len; i++ {
+				n := len(df.path)
+				df.path = strconv.AppendInt(append(df.path, '['), int64(i), 10)
+				df.path = append(df.path, ']')
+				switch {
+				case i >= ah.len:
+					df.add(et, nil, unsafe.Add(bh.data, i*size))
+				case i >= bh.len:
+					df.add(et, unsafe.Add(ah.data, i*size), nil)
+				default:
+					eEng(df, unsafe.Add(ah.data, i*size), unsafe.Add(bh.data, i*size))
+				}
+				df.path = df.path[:n]
+			}
+		}
+	case kind == reflect.Map:
+		vt := rt.Elem()
+		defer buildDiffEngine(vt, &eEng)
+		engine = func(df *differ, a, b unsafe.Pointer) {
+			if aNil, bNil := isNil(a), isNil(b); aNil || bNil {
+				if aNil != bNil {
+					df.add(rt, a, b)
+				}
+				return
+			}
+			av, bv := reflect.NewAt(rt, a).Elem(), reflect.NewAt(rt, b).Elem()
+			keys := av.MapKeys()
+			for _, k := range bv.MapKeys() {
+				if !av.MapIndex(k).IsValid() {
+					keys = append(keys, k)
+				}
+			}
+			names := make([]string, len(keys))
+			for i, k := range keys {
+				names[i] = mapKeyString(k)
+			}
+			sort.Stable(keysByName{keys, names, rt.Key()})
+			for i, k := range keys {
+				n := len(df.path)
+				df.path = append(append(append(df.path, '['), names[i]...), ']')
+				switch ae, be := av.MapIndex(k), bv.MapIndex(k); {
+				case !ae.IsValid():
+					df.add(vt, nil, getUnsafePointer(be))
+				case !be.IsValid():
+					df.add(vt, getUnsafePointer(ae), nil)
+				default:
+					eEng(df, getUnsafePointer(ae), getUnsafePointer(be))
+				}
+				df.path = df.path[:n]
+			}
+		}
+	case kind == reflect.Struct:
+		fields, offs, paths := getFieldPath(rt, 0, "")
+		nf := len(fields)
+		fEngines := make([]diffEng, nf)
+		defer func() {
+			for i := 0; i < nf; i++ {
+				buildDiffEngine(fields[i], &fEngines[i])
+			}
+		}()
+		engine = func(df *differ, a, b unsafe.Pointer) {
+			for i := 0; i < len(fEngines) && i < len(offs); i++ {
+				n := len(df.path)
+				if n > 0 {
+					df.path = append(df.path, '.')
+				}
+				df.path = append(df.path, paths[i]...)
+				fEngines[i](df, unsafe.Add(a, offs[i]), unsafe.Add(b, offs[i]))
+				df.path = df.path[:n]
+			}
+		}
+	case kind == reflect.Interface:
+		engine = func(df *differ, a, b unsafe.Pointer) {
+			av, bv := reflect.NewAt(rt, a).Elem(), reflect.NewAt(rt, b).Elem()
+			switch {
+			case av.IsNil() && bv.IsNil():
+			case av.IsNil() || bv.IsNil() || av.Elem().Type() != bv.Elem().Type():
+				df.add(rt, a, b)
+			default:
+				getDiffEngine(av.Elem().Type())(df, getUnsafePointer(av.Elem()), getUnsafePointer(bv.Elem()))
+			}
+		}
+	case kind == reflect.Chan, kind == reflect.Func, kind == reflect.UnsafePointer, kind == reflect.Invalid:
+		panic("not support " + rt.String() + " type")
+	default:
+		engine = leafDiffEngine(rt)
+	}
+	rt2diffEng[rt] = engine
+	*engPtr = engine
+}
+
+// leafDiffEngine returns a diff engine that compares values of rt as a whole.
+func leafDiffEngine(rt reflect.Type) diffEng {
+	eng := getEncEngine(rt)
+	return func(df *differ, a, b unsafe.Pointer) {
+		if !df.equal(eng, a, b) {
+			df.add(rt, a, b)
+		}
+	}
+}
+
+// mapKeyString formats a map key for use in a Difference path.
+func mapKeyString(k reflect.Value) string {
+	if k.Kind() == reflect.String {
+		return strconv.Quote(k.String())
+	}
+	return fmt.Sprint(k.Interface())
+}
+
+// keysByName sorts map keys by their formatted names, and keys with the same name
+// by their canonical encoding.
+type keysByName struct {
+	keys  []reflect.Value
+	names []string
+	kt    reflect.Type // the key type of the map
+}
+
+func (s keysByName) Len() int { return len(s.keys) }
+func (s keysByName) Less(i, j int) bool {
+	if s.names[i] != s.names[j] {
+		return s.names[i] < s.names[j]
+	}
+	// keys of different types can have the same name, as int(1) and "1" in a map[any]T
+	return bytes.Compare(s.encode(i), s.encode(j)) < 0
+}
+
+// encode returns the canonical encoding of the i-th key.
+func (s keysByName) encode(i int) []byte {
+	e := Encoder{canonical: true}
+	getEncEngine(s.kt)(&e, getUnsafePointer(s.keys[i]))
+	return e.buf
+}
+
+func (s keysByName) Swap(i, j int) {
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+	s.names[i], s.names[j] = s.names[j], s.names[i]
+}
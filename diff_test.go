@@ -0,0 +1,93 @@
+package gotiny
+
+import (
+	"reflect"
+	"testing"
+)
+
+type diffItem struct {
+	Name  string
+	Price float64
+}
+
+type diffTyp struct {
+	ID     int
+	Note   string `gotiny:"-"`
+	Owner  *tA
+	Items  []diffItem
+	Tags   map[string]int
+	Extra  any
+	Nested struct{ A, B int }
+}
+
+func newDiffTyp() diffTyp {
+	return diffTyp{
+		ID:    1,
+		Note:  "a",
+		Owner: &tA{Name: "bob"},
+		Items: []diffItem{{"x", 1}, {"y", 2}},
+		Tags:  map[string]int{"a": 1, "b": 2},
+		Extra: []int{1},
+	}
+}
+
+func TestEqual(t *testing.T) {
+	for i, src := range srci {
+		dst := reflect.New(typs[i]).Interface()
+		Unmarshal(Marshal(src), dst)
+		if Equal(src, dst) != reflect.DeepEqual(src, dst) {
+			t.Errorf("Equal(%T) disagrees with reflect.DeepEqual", src)
+		}
+	}
+	a, b := newDiffTyp(), newDiffTyp()
+	b.Note = "ignored"
+	if !Equal(a, b) || len(Diff(a, b)) != 0 {
+		t.Fatal("values differing only in ignored fields are not equal")
+	}
+	b.Tags["a"] = 3
+	if Equal(a, b) {
+		t.Fatal("different values are equal")
+	}
+	if Equal(1, int64(1)) {
+		t.Fatal("values of different types are equal")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a, b := newDiffTyp(), newDiffTyp()
+	b.Owner.Name = "alice"
+	b.Items[1].Price = 3
+	b.Items = append(b.Items, diffItem{"z", 4})
+	delete(b.Tags, "a")
+	b.Tags["c"] = 3
+	b.Extra = []int{1, 2}
+	b.Nested.B = 5
+
+	want := []Difference{
+		{"Owner.Name", "bob", "alice"},
+		{"Items[1].Price", 2.0, 3.0},
+		{"Items[2]", nil, diffItem{"z", 4}},
+		{`Tags["a"]`, 1, nil},
+		{`Tags["c"]`, nil, 3},
+		{"Extra[1]", nil, 2},
+		{"Nested.B", 0, 5},
+	}
+	if got := Diff(a, b); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got  %v\nwant %v", got, want)
+	}
+	if got := Diff(1, "1"); len(got) != 1 || got[0].Path != "" {
+		t.Fatalf("got %v for values of different types", got)
+	}
+
+	// keys with the same name come out in the same order every time
+	m := map[any]int{1: 1, "1": 2, int8(1): 3, uint(1): 4}
+	first := Diff(map[any]int{}, m)
+	if len(first) != len(m) {
+		t.Fatalf("got %v", first)
+	}
+	for i := 0; i < 20; i++ {
+		if got := Diff(map[any]int{}, m); !reflect.DeepEqual(got, first) {
+			t.Fatalf("got  %v\nthen %v", first, got)
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package gotiny
 
 import (
+	"bytes"
 	"reflect"
+	"sort"
 	"sync"
 	"time"
 	"unsafe"
@@ -61,22 +63,43 @@ var (
 		reflect.String:     encString,
 	}
 
-	encLock sync.RWMutex
+	encLock sync.Mutex
+	encSnap snapshot[reflect.Type, encEng]
 )
 
 // UnusedUnixNanoEncodeTimeType removes the encoding and decoding engine
 // for the time.Time type from the rt2encEng and rt2decEng maps, respectively.
 // This function is used to disable the encoding and decoding of time.Time
 // values using UnixNano format.
+//
+// It must be called before any value is encoded, decoded, sized, skipped or
+// fingerprinted, typically from an init function. Engines built before it,
+// the pooled encoders and decoders holding them, and the other caches keep
+// using the UnixNano format for time.Time values and for the types containing them.
 func UnusedUnixNanoEncodeTimeType() {
-	delete(rt2encEng, reflect.TypeOf((*time.Time)(nil)).Elem())
-	delete(rt2decEng, reflect.TypeOf((*time.Time)(nil)).Elem())
+	rt := reflect.TypeOf((*time.Time)(nil)).Elem()
+	encLock.Lock()
+	delete(rt2encEng, rt)
+	encSnap.store(rt2encEng)
+	encLock.Unlock()
+	decLock.Lock()
+	delete(rt2decEng, rt)
+	decSnap.store(rt2decEng)
+	decLock.Unlock()
+	skipLock.Lock()
+	delete(rt2skipEng, rt)
+	skipSnap.store(rt2skipEng)
+	skipLock.Unlock()
+	sizeLock.Lock()
+	delete(rt2sizeEng, rt)
+	sizeSnap.store(rt2sizeEng)
+	sizeLock.Unlock()
 }
 
 // getEncEngine retrieves or builds an encoding engine for the given reflect.Type.
-// It first attempts to retrieve the engine from a cache using a read lock.
-// If the engine is not found in the cache, it acquires a write lock, builds the engine,
-// stores it in the cache, and then returns the newly built engine.
+// It first looks the engine up in the latest snapshot of the cache, without locking.
+// If the engine is not found there, it acquires the lock, builds the engine,
+// stores a new snapshot of the cache, and then returns the newly built engine.
 //
 // Parameters:
 //
@@ -86,18 +109,45 @@ func UnusedUnixNanoEncodeTimeType() {
 //
 //	encEng - the encoding engine associated with the given reflect.Type.
 func getEncEngine(rt reflect.Type) encEng {
-	encLock.RLock()
-	engine := rt2encEng[rt]
-	encLock.RUnlock()
-	if engine != nil {
+	if engine := encSnap.load()[rt]; engine != nil {
 		return engine
 	}
 	encLock.Lock()
+	defer encLock.Unlock()
+	var engine encEng
 	buildEncEngine(rt, &engine)
-	encLock.Unlock()
+	encSnap.store(rt2encEng)
 	return engine
 }
 
+// ifaceEnc holds what encoding an interface value with a given dynamic type
+// takes: the name the type is registered with and the engine of the type.
+type ifaceEnc struct {
+	name string
+	eng  encEng
+}
+
+var (
+	// rt2ifaceEnc caches the ifaceEnc of the dynamic types of encoded interface values,
+	// so that encoding such a value takes a single lookup in a snapshot.
+	rt2ifaceEnc  = map[reflect.Type]ifaceEnc{}
+	ifaceEncLock sync.Mutex
+	ifaceEncSnap snapshot[reflect.Type, ifaceEnc]
+)
+
+// getIfaceEnc retrieves or builds the ifaceEnc of the dynamic type rt.
+func getIfaceEnc(rt reflect.Type) ifaceEnc {
+	if ie, has := ifaceEncSnap.load()[rt]; has {
+		return ie
+	}
+	ie := ifaceEnc{name: getNameOfType(rt), eng: getEncEngine(rt)}
+	ifaceEncLock.Lock()
+	defer ifaceEncLock.Unlock()
+	rt2ifaceEnc[rt] = ie
+	ifaceEncSnap.store(rt2ifaceEnc)
+	return ie
+}
+
 // buildEncEngine constructs an encoding engine for the given reflect.Type and assigns it to the provided encEng pointer.
 // It first checks if an engine for the type already exists in the cache (rt2encEng).
 // If not, it attempts to implement another serializer for the type.
@@ -118,8 +168,40 @@ func buildEncEngine(rt reflect.Type, engPtr *encEng) {
 		return
 	}
 
+	if isPOD(rt) {
+		engine = buildPODEncEngine(rt)
+		rt2encEng[rt] = engine
+		*engPtr = engine
+		return
+	}
+
 	kind := rt.Kind()
 	var eEng encEng
+	if et := rawElem(rt); et != nil {
+		defer buildEncEngine(et, &eEng)
+		zero := reflect.New(et).UnsafePointer()
+		engine = func(e *Encoder, p unsafe.Pointer) {
+			raw := *(*[]byte)(p)
+			switch {
+			case raw == nil:
+				// the encoding of a Raw stands on its own, so encode the zero value separately
+				re := Encoder{canonical: e.canonical}
+				eEng(&re, zero)
+				raw = re.buf
+			case e.canonical:
+				// the bytes may come from an encoder that does not sort map entries
+				v := reflect.New(et).UnsafePointer()
+				getDecEngine(et)(&Decoder{buf: raw}, v)
+				re := Encoder{canonical: true}
+				eEng(&re, v)
+				raw = re.buf
+			}
+			e.buf = append(e.buf, raw...)
+		}
+		rt2encEng[rt] = engine
+		*engPtr = engine
+		return
+	}
 	switch kind {
 	case reflect.Ptr:
 		defer buildEncEngine(rt.Elem(), &eEng)
@@ -133,8 +215,16 @@ func buildEncEngine(rt reflect.Type, engPtr *encEng) {
 	case reflect.Array:
 		et, l := rt.Elem(), rt.Len()
 		size := et.Size()
+		if b := getBulkEngine(et); b != nil {
+			engine = func(e *Encoder, p unsafe.Pointer) { encBulk(e, b, p, l, size) }
+			break
+		}
 		defer buildEncEngine(et, &eEng)
 		engine = func(e *Encoder, p unsafe.Pointer) {
+			if e.w != nil {
+				encStreamElems(e, eEng, p, l, size)
+				return
+			}
 			for i := 0; i < l; i++ {
 				eEng(e, unsafe.Add(p, i*int(size)))
 			}
@@ -142,6 +232,10 @@ func buildEncEngine(rt reflect.Type, engPtr *encEng) {
 	case reflect.Slice:
 		et := rt.Elem()
 		size := et.Size()
+		if b := getBulkEngine(et); b != nil {
+			engine = bulkSliceEncEngine(b, size)
+			break
+		}
 		defer buildEncEngine(et, &eEng)
 		engine = func(e *Encoder, p unsafe.Pointer) {
 			isNotNil := !isNil(p)
@@ -150,6 +244,10 @@ func buildEncEngine(rt reflect.Type, engPtr *encEng) {
 				header := (*sliceHeader)(p)
 				l := header.len
 				e.encLength(l)
+				if e.w != nil {
+					encStreamElems(e, eEng, header.data, l, size)
+					return
+				}
 				for i := 0; i < l; i++ {
 					eEng(e, unsafe.Add(header.data, i*int(size)))
 				}
@@ -157,18 +255,29 @@ func buildEncEngine(rt reflect.Type, engPtr *encEng) {
 		}
 	case reflect.Map:
 		var kEng encEng
-		defer buildEncEngine(rt.Key(), &kEng)
-		defer buildEncEngine(rt.Elem(), &eEng)
+		kt, et := rt.Key(), rt.Elem()
+		defer buildEncEngine(kt, &kEng)
+		defer buildEncEngine(et, &eEng)
 		engine = func(e *Encoder, p unsafe.Pointer) {
 			isNotNil := !isNil(p)
 			e.encIsNotNil(isNotNil)
 			if isNotNil {
 				v := reflect.NewAt(rt, p).Elem()
 				e.encLength(v.Len())
-				iter := v.MapRange()
+				// streaming encoders are canonical, so only encSortedMap needs to flush
+				if e.canonical {
+					encSortedMap(e, v, kEng, eEng)
+					return
+				}
+				// copy the entries into the same storage instead of allocating each of them
+				key, val := reflect.New(kt).Elem(), reflect.New(et).Elem()
+				var iter reflect.MapIter
+				iter.Reset(v)
 				for iter.Next() {
-					kEng(e, getUnsafePointer(iter.Key()))
-					eEng(e, getUnsafePointer(iter.Value()))
+					key.SetIterKey(&iter)
+					val.SetIterValue(&iter)
+					kEng(e, unsafe.Pointer(key.UnsafeAddr()))
+					eEng(e, unsafe.Pointer(val.UnsafeAddr()))
 				}
 			}
 		}
@@ -193,28 +302,108 @@ func buildEncEngine(rt reflect.Type, engPtr *encEng) {
 				e.encIsNotNil(isNotNil)
 				if isNotNil {
 					v := reflect.ValueOf(*(*interface{ M() })(p))
-					et := v.Type()
-					e.encString(getNameOfType(et))
-					getEncEngine(et)(e, getUnsafePointer(v))
+					ie := getIfaceEnc(v.Type())
+					e.encString(ie.name)
+					ie.eng(e, getUnsafePointer(v))
 				}
 			}
 		} else {
-			engine = func(e *Encoder, p unsafe.Pointer) {
-				isNotNil := !isNil(p)
-				e.encIsNotNil(isNotNil)
-				if isNotNil {
-					v := reflect.ValueOf(*(*any)(p))
-					et := v.Type()
-					e.encString(getNameOfType(et))
-					getEncEngine(et)(e, getUnsafePointer(v))
-				}
-			}
+			engine = encEface
 		}
 	case reflect.Chan, reflect.Func, reflect.UnsafePointer, reflect.Invalid:
 		panic("not support " + rt.String() + " type")
 	default:
 		engine = encEngines[kind]
 	}
+	if isNestedSerializer(rt) {
+		engine = nestedEncEngine(rt, engine)
+	}
 	rt2encEng[rt] = engine
 	*engPtr = engine
 }
+
+// encStreamElems encodes the n elements of the given size starting at p with eng,
+// flushing the buffer of the streaming encoder e in between. The engines only call
+// it for encoders with a writer, so that the loops of Marshal do not check for flushes.
+func encStreamElems(e *Encoder, eng encEng, p unsafe.Pointer, n int, size uintptr) {
+	for i := 0; i < n; i++ {
+		eng(e, unsafe.Add(p, i*int(size)))
+		e.checkFlush()
+	}
+}
+
+// encSortedMap encodes the entries of the map v ordered by the encoding of
+// their keys, so that the output does not depend on the map iteration order.
+func encSortedMap(e *Encoder, v reflect.Value, kEng, eEng encEng) {
+	type entry struct {
+		enc  []byte // the encoded key, used only for ordering
+		k, v reflect.Value
+	}
+	entries := make([]entry, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		k := iter.Key()
+		ke := Encoder{canonical: true}
+		kEng(&ke, getUnsafePointer(k))
+		entries = append(entries, entry{enc: ke.buf, k: k, v: iter.Value()})
+	}
+	sort.Slice(entries, func(i, j int) bool { return bytes.Compare(entries[i].enc, entries[j].enc) < 0 })
+	for i := range entries {
+		kEng(e, getUnsafePointer(entries[i].k))
+		eEng(e, getUnsafePointer(entries[i].v))
+		e.checkFlush()
+	}
+}
+
+// buildMaskedEncEngine constructs an engine that encodes the fields of rt selected by mask,
+// as described by EncodeMasked. Unlike the engines built by buildEncEngine it depends on
+// the mask and is therefore not cached.
+func buildMaskedEncEngine(rt reflect.Type, mask FieldMask) encEng {
+	if !isMaskable(rt) {
+		panic("gotiny: cannot select fields of " + rt.String())
+	}
+	if rt.Kind() == reflect.Ptr {
+		eEng := buildMaskedEncEngine(rt.Elem(), mask)
+		return func(e *Encoder, p unsafe.Pointer) {
+			isNotNil := !isNil(p)
+			e.encIsNotNil(isNotNil)
+			if isNotNil {
+				eEng(e, *(*unsafe.Pointer)(p))
+			}
+		}
+	}
+
+	fields := getDirectFields(rt)
+	for name := range mask {
+		if f, has := rt.FieldByName(name); !has || ignoreField(f) || len(f.Index) > 1 {
+			panic("gotiny: " + rt.String() + " has no field " + name)
+		}
+	}
+	var (
+		bits    []bool // presence bits, each followed by a partial bit if set
+		offs    []uintptr
+		engines []encEng
+	)
+	for _, field := range fields {
+		sub, has := mask[field.Name]
+		bits = append(bits, has)
+		if !has {
+			continue
+		}
+		bits = append(bits, sub != nil)
+		offs = append(offs, field.Offset)
+		if sub != nil {
+			engines = append(engines, buildMaskedEncEngine(field.Type, sub))
+		} else {
+			engines = append(engines, getEncEngine(field.Type))
+		}
+	}
+	return func(e *Encoder, p unsafe.Pointer) {
+		for _, bit := range bits {
+			e.encBool(bit)
+		}
+		for i := 0; i < len(engines) && i < len(offs); i++ {
+			engines[i](e, unsafe.Add(p, offs[i]))
+		}
+	}
+}
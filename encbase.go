@@ -1,6 +1,8 @@
 package gotiny
 
 import (
+	"math"
+	"reflect"
 	"time"
 	"unsafe"
 )
@@ -73,8 +75,10 @@ func (e *Encoder) encUint32(v uint32) {
 	}
 }
 
-func (e *Encoder) encLength(v int)    { e.encUint32(uint32(v)) }
-func (e *Encoder) encString(s string) { e.encUint32(uint32(len(s))); e.buf = append(e.buf, s...) }
+// encLength encodes a length as a uint64, which writes the same bytes as the uint32
+// lengths were written with before for every length below 2^32.
+func (e *Encoder) encLength(v int)    { e.encUint64(uint64(v)) }
+func (e *Encoder) encString(s string) { e.encLength(len(s)); e.buf = append(e.buf, s...) }
 func (e *Encoder) encIsNotNil(v bool) { e.encBool(v) }
 
 func encIgnore(*Encoder, unsafe.Pointer)      {}
@@ -90,18 +94,31 @@ func encUint32(e *Encoder, p unsafe.Pointer)  { e.encUint32(*(*uint32)(p)) }
 func encUint64(e *Encoder, p unsafe.Pointer)  { e.encUint64(uint64(*(*uint64)(p))) }
 func encUint(e *Encoder, p unsafe.Pointer)    { e.encUint64(uint64(*(*uint)(p))) }
 func encUintptr(e *Encoder, p unsafe.Pointer) { e.encUint64(uint64(*(*uintptr)(p))) }
-func encFloat32(e *Encoder, p unsafe.Pointer) { e.encUint32(float32ToUint32(p)) }
-func encFloat64(e *Encoder, p unsafe.Pointer) { e.encUint64(float64ToUint64(p)) }
+func encFloat32(e *Encoder, p unsafe.Pointer) { e.encUint32(float32ToUint32(*(*float32)(p))) }
+func encFloat64(e *Encoder, p unsafe.Pointer) { e.encUint64(float64ToUint64(*(*float64)(p))) }
 func encString(e *Encoder, p unsafe.Pointer) {
 	s := *(*string)(p)
-	e.encUint32(uint32(len(s)))
+	e.encLength(len(s))
 	e.buf = append(e.buf, s...)
 }
 func encTime(e *Encoder, p unsafe.Pointer)      { e.encUint64(uint64((*time.Time)(p).UnixNano())) }
-func encComplex64(e *Encoder, p unsafe.Pointer) { e.encUint64(*(*uint64)(p)) }
+func encComplex64(e *Encoder, p unsafe.Pointer) { e.encUint64(complex64ToUint64(*(*complex64)(p))) }
 func encComplex128(e *Encoder, p unsafe.Pointer) {
-	e.encUint64(*(*uint64)(p))
-	e.encUint64(*(*uint64)(unsafe.Add(p, 8)))
+	c := *(*complex128)(p)
+	e.encUint64(math.Float64bits(real(c)))
+	e.encUint64(math.Float64bits(imag(c)))
+}
+
+// encEface encodes a value of type any as the name of its dynamic type followed by its encoding.
+func encEface(e *Encoder, p unsafe.Pointer) {
+	isNotNil := !isNil(p)
+	e.encIsNotNil(isNotNil)
+	if isNotNil {
+		v := reflect.ValueOf(*(*any)(p))
+		ie := getIfaceEnc(v.Type())
+		e.encString(ie.name)
+		ie.eng(e, getUnsafePointer(v))
+	}
 }
 
 func encBytes(e *Encoder, p unsafe.Pointer) {
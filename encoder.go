@@ -1,7 +1,9 @@
 package gotiny
 
 import (
+	"io"
 	"reflect"
+	"sync"
 )
 
 // Encoder is a structure that holds the state and buffer for encoding operations.
@@ -12,6 +14,9 @@ import (
 // - boolBit: a byte representing the bit position of the next boolean value to be set in buf[boolPos].
 // - engines: a slice of encEng, which are the encoding engines used for encoding operations.
 // - length: an integer representing the length of the encoded data.
+// - canonical: whether maps are encoded in a deterministic order.
+// - packedFloats: whether slices and arrays of floats are encoded with fixed width.
+// - w: an optional writer that receives the encoded bytes as they are completed.
 type Encoder struct {
 	buf     []byte // encoded target array
 	off     int
@@ -20,6 +25,11 @@ type Encoder struct {
 
 	engines []encEng
 	length  int
+
+	canonical    bool      // encode map entries sorted by their encoded key
+	packedFloats bool      // encode slices and arrays of floats with fixed width
+	w            io.Writer // if not nil, completed bytes are flushed to w; only canonical encoders have one
+	err          error     // the first error returned by w
 }
 
 /*
@@ -30,7 +40,107 @@ then you can pass in value directly,
 which serializes the value pointed to by value.
 */
 func Marshal(ps ...any) []byte {
-	return NewEncoderWithPtr(ps...).encode(ps...)
+	return MarshalAppend(nil, ps...)
+}
+
+// MarshalAppend works like Marshal, but appends the encoded data to dst and returns
+// the extended buffer. Encoders are pooled per list of argument types, so encoding
+// into a buffer with enough spare capacity does not allocate, unless a value
+// contains a map or an interface.
+func MarshalAppend(dst []byte, ps ...any) []byte {
+	return marshalAppend(dst, false, ps)
+}
+
+// marshalAppend is MarshalAppend with packed floats if packed is true.
+func marshalAppend(dst []byte, packed bool, ps []any) []byte {
+	key, ok := getTypesKey(ps)
+	if !ok {
+		e := NewEncoderWithPtr(ps...)
+		e.buf, e.packedFloats = dst, packed
+		return e.encode(ps...)
+	}
+	pool := getEncPool(key)
+	e := pool.Get().(*Encoder)
+	e.buf, e.packedFloats = dst, packed
+	buf := e.encode(ps...)
+	e.buf = nil // do not keep the caller's buffer alive
+	pool.Put(e)
+	return buf
+}
+
+// Size returns the exact length of the bytes Marshal(ps...) would produce,
+// without encoding anything. It can be used to allocate a buffer for
+// MarshalAppend or to reject oversize values up front.
+// Types whose encoding always has the same length are sized in constant time.
+func Size(ps ...any) int {
+	var s sizer
+	for _, p := range ps {
+		rt := reflect.TypeOf(p)
+		if rt.Kind() != reflect.Ptr {
+			panic("must a pointer type!")
+		}
+		getSizeEngine(rt.Elem())(&s, reflect.ValueOf(p).UnsafePointer())
+	}
+	return s.size()
+}
+
+// maxPooledTypes is the length of the longest argument lists that Marshal and
+// Unmarshal use pooled encoders and decoders for.
+const maxPooledTypes = 4
+
+// typesKey identifies the list of the types of up to maxPooledTypes arguments.
+type typesKey [maxPooledTypes]reflect.Type
+
+var (
+	encPools    = map[typesKey]*sync.Pool{}
+	encPoolLock sync.Mutex
+	encPoolSnap snapshot[typesKey, *sync.Pool]
+)
+
+// getTypesKey returns the key of the types of ps, or false if there are too many
+// of them or one of them is nil, which the unpooled path rejects.
+func getTypesKey(ps []any) (key typesKey, ok bool) {
+	if len(ps) > maxPooledTypes {
+		return key, false
+	}
+	for i, p := range ps {
+		if key[i] = reflect.TypeOf(p); key[i] == nil {
+			return key, false
+		}
+	}
+	return key, true
+}
+
+// elemTypes returns the types pointed to by the pointer types in key.
+func (key typesKey) elemTypes() []reflect.Type {
+	ts := make([]reflect.Type, 0, maxPooledTypes)
+	for _, rt := range key {
+		if rt == nil {
+			break
+		}
+		if rt.Kind() != reflect.Ptr {
+			panic("must a pointer type!")
+		}
+		ts = append(ts, rt.Elem())
+	}
+	return ts
+}
+
+// getEncPool returns the pool of encoders for the pointer types in key.
+func getEncPool(key typesKey) *sync.Pool {
+	if pool := encPoolSnap.load()[key]; pool != nil {
+		return pool
+	}
+	ts := key.elemTypes()
+	encPoolLock.Lock()
+	defer encPoolLock.Unlock()
+	pool := encPools[key]
+	if pool == nil {
+		pool = &sync.Pool{New: func() any { return NewEncoderWithType(ts...) }}
+		encPools[key] = pool
+		encPoolSnap.store(encPools)
+	}
+	return pool
 }
 
 // Create an encoder for the types pointed to by ps
@@ -75,6 +185,27 @@ func NewEncoderWithType(ts ...reflect.Type) *Encoder {
 	}
 }
 
+// SetPackedFloats selects how e encodes slices and arrays of float32, float64,
+// complex64 and complex128. By default each number is a varint, like a single
+// float field, as Marshal writes it. When on is true, each number, or each part
+// of a complex number, is written as its IEEE 754 bits in little-endian byte order
+// using 4 or 8 bytes, which is faster and smaller for floats that use the whole
+// mantissa. The output must be decoded by a Decoder with the same setting; an
+// envelope records it, see EnvelopeOptions.PackedFloats.
+//
+// The setting does not reach types with their own encoding, such as those
+// implementing Serializer, nor Raw values, which always hold what Marshal writes.
+func (e *Encoder) SetPackedFloats(on bool) {
+	e.packedFloats = on
+}
+
+// Encode encodes the values pointed to by ps, which must have the types the encoder
+// was created for, and returns the encoded bytes, appended to the buffer given to
+// AppendTo, if any.
+func (e *Encoder) Encode(ps ...any) []byte {
+	return e.encode(ps...)
+}
+
 // The input parameter is a pointer to the value to be encoded
 func (e *Encoder) encode(is ...any) []byte {
 	engines := e.engines
@@ -110,3 +241,33 @@ func (e *Encoder) reset() []byte {
 	e.boolPos = 0
 	return buf
 }
+
+// flushSize is the amount of buffered data after which an encoder with a
+// writer hands its completed bytes to the writer.
+const flushSize = 4 << 10
+
+// checkFlush flushes the buffer if the encoder has a writer and enough data
+// has been buffered.
+func (e *Encoder) checkFlush() {
+	if e.w != nil && len(e.buf) >= flushSize {
+		e.flush()
+	}
+}
+
+// flush writes the completed part of the buffer to e.w and drops it from the
+// buffer. The byte that is still collecting bools, and everything after it,
+// stays in the buffer because it can still change.
+func (e *Encoder) flush() {
+	n := len(e.buf)
+	if e.boolBit != 0 {
+		n = e.boolPos
+		e.boolPos = 0
+	}
+	if n == 0 {
+		return
+	}
+	if e.err == nil {
+		_, e.err = e.w.Write(e.buf[:n])
+	}
+	e.buf = e.buf[:copy(e.buf, e.buf[n:])]
+}
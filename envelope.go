@@ -0,0 +1,445 @@
+package gotiny
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FormatVersion is the version of the wire format specified in SPEC.md that this
+// package encodes. It is written in every envelope.
+const FormatVersion = 1
+
+// An envelope wraps the encoding of values, as an Encoder writes it, in a header
+// that identifies it as gotiny data:
+//
+//	magic       4 bytes  "gtny"
+//	version     1 byte   FormatVersion
+//	flags       1 byte   which of the optional fields below are present, the compression, and packed floats
+//	fingerprint 8 bytes  Fingerprint of the encoded types, little-endian, if flagFingerprint is set
+//	dictionary  4 bytes  ID of the preset dictionary of the compression, little-endian, if flagDictionary is set
+//	length      varint   length of the payload, encoded like any other length
+//	payload     length bytes, compressed as the flags say
+//	checksum    4 bytes  CRC-32C of all the bytes above, little-endian, if flagChecksum is set
+const envelopeMagic = "gtny"
+
+// The flags of an envelope. Envelopes with flags not listed here are rejected.
+const (
+	flagFingerprint byte = 1 << 0 // the fingerprint is present
+	// bits 1 and 2 hold the Compression of the payload
+	compressionShift      = 1
+	compressionMask       = 3
+	flagDictionary   byte = 1 << 3 // the payload is compressed with a preset dictionary
+	flagChecksum     byte = 1 << 4 // the checksum follows the payload
+	flagPackedFloats byte = 1 << 5 // the payload is encoded with packed floats
+
+	knownFlags = flagFingerprint | compressionMask<<compressionShift | flagDictionary | flagChecksum | flagPackedFloats
+)
+
+// checksumLen is the length of the checksum of an envelope.
+const checksumLen = 4
+
+// castagnoli is the table of the CRC-32C checksums of envelopes.
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+var (
+	// ErrNotEnvelope is returned when the data does not start with the magic of an envelope.
+	ErrNotEnvelope = errors.New("gotiny: not an envelope")
+	// ErrVersion is returned for envelopes of a format version this package cannot decode.
+	ErrVersion = errors.New("gotiny: unsupported format version")
+	// ErrFingerprint is returned when the fingerprint recorded in an envelope is not the
+	// one of the types decoded into.
+	ErrFingerprint = errors.New("gotiny: fingerprint mismatch")
+	// ErrDictionary is returned for envelopes compressed with a preset dictionary
+	// that is not registered.
+	ErrDictionary = errors.New("gotiny: unknown dictionary")
+	// ErrChecksum is returned for envelopes whose checksum does not match their
+	// contents, which were therefore corrupted.
+	ErrChecksum = errors.New("gotiny: checksum mismatch")
+	// ErrTooLarge is returned for envelopes whose payload decompresses to more
+	// than DecodeOptions.MaxDecompressedLen bytes.
+	ErrTooLarge = errors.New("gotiny: decompressed payload too large")
+)
+
+// DefaultMaxDecompressedLen is the length to which payloads are decompressed at
+// most when DecodeOptions.MaxDecompressedLen is 0.
+const DefaultMaxDecompressedLen = 64 << 20
+
+// DecodeOptions restricts the envelopes the decoding functions accept.
+type DecodeOptions struct {
+	// MaxDecompressedLen is the length beyond which a compressed payload is not
+	// decompressed, so that a small envelope cannot make the decoding functions
+	// allocate without bound; 0 selects DefaultMaxDecompressedLen and a negative
+	// value removes the limit.
+	MaxDecompressedLen int
+	// RequireChecksum rejects envelopes without a checksum with ErrChecksum.
+	// Without it, damage that clears the checksum flag goes undetected.
+	RequireChecksum bool
+}
+
+// check returns an error if o rejects envelopes with the header h.
+func (o DecodeOptions) check(h Header) error {
+	if o.RequireChecksum && !h.HasChecksum {
+		return fmt.Errorf("%w: the envelope has no checksum", ErrChecksum)
+	}
+	return nil
+}
+
+// maxDecompressedLen returns the limit o selects, or -1 for none.
+func (o DecodeOptions) maxDecompressedLen() int {
+	switch {
+	case o.MaxDecompressedLen == 0:
+		return DefaultMaxDecompressedLen
+	case o.MaxDecompressedLen < 0:
+		return -1
+	}
+	return o.MaxDecompressedLen
+}
+
+// EnvelopeOptions selects the optional fields of the envelopes MarshalEnvelope writes.
+type EnvelopeOptions struct {
+	// Fingerprint records the Fingerprint of the encoded types, which
+	// UnmarshalEnvelope then checks against the types it decodes into.
+	Fingerprint bool
+	// Compression selects the algorithm that compresses the payload. Payloads
+	// shorter than CompressThreshold, and those that compression does not make
+	// shorter, are stored uncompressed.
+	Compression Compression
+	// CompressionLevel is the level passed to the compressor, from flate.BestSpeed
+	// to flate.BestCompression; 0 selects flate.DefaultCompression, or
+	// flate.BestCompression if Dictionary is set.
+	CompressionLevel int
+	// CompressThreshold is the length from which payloads are compressed; 0 selects
+	// DefaultCompressThreshold, or no threshold if Dictionary is set.
+	CompressThreshold int
+	// Dictionary is the ID of a preset dictionary registered with RegisterDictionary
+	// to compress with, or 0 for none. It requires Flate or Zlib compression.
+	Dictionary uint32
+	// Checksum appends a CRC-32C checksum of the envelope, which the decoding
+	// functions verify before anything else, so that corrupted data is reported
+	// with ErrChecksum instead of being decoded into wrong values. Readers must set
+	// DecodeOptions.RequireChecksum to detect damage that clears its flag.
+	Checksum bool
+	// PackedFloats encodes the payload with packed floats, as described by
+	// Encoder.SetPackedFloats, and records it in the envelope, so that the
+	// decoding functions decode the payload with the same setting.
+	PackedFloats bool
+}
+
+// Header describes an envelope, as read by ReadHeader.
+type Header struct {
+	Version        byte        // format version of the payload
+	HasFingerprint bool        // whether Fingerprint was recorded
+	Fingerprint    uint64      // fingerprint of the encoded types
+	Compression    Compression // algorithm the payload is compressed with
+	Dictionary     uint32      // ID of the preset dictionary the payload is compressed with, or 0
+	HasChecksum    bool        // whether a checksum follows the payload
+	PackedFloats   bool        // whether the payload is encoded with packed floats
+	PayloadLen     int         // length of the payload
+	Len            int         // length of the header, so that the payload starts at buf[Len:]
+}
+
+// MarshalEnvelope encodes the values pointed to by ps like Marshal, or with packed
+// floats if opts says so, and wraps the result in an envelope with the fields
+// selected by opts.
+func MarshalEnvelope(opts EnvelopeOptions, ps ...any) []byte {
+	return appendEnvelope(nil, opts, marshalAppend(nil, opts.PackedFloats, ps), ps)
+}
+
+// appendEnvelope appends to dst an envelope holding payload, the encoding of the
+// values pointed to by ps.
+func appendEnvelope(dst []byte, opts EnvelopeOptions, payload []byte, ps []any) []byte {
+	start := len(dst)
+	var flags byte
+	if opts.Fingerprint {
+		flags |= flagFingerprint
+	}
+	if opts.Checksum {
+		flags |= flagChecksum
+	}
+	if opts.PackedFloats {
+		flags |= flagPackedFloats
+	}
+	compressed, ok := compress(opts, payload)
+	if ok {
+		payload = compressed
+		flags |= byte(opts.Compression) << compressionShift
+		if opts.Dictionary != 0 {
+			flags |= flagDictionary
+		}
+	}
+	e := Encoder{buf: dst}
+	e.buf = append(e.buf, envelopeMagic...)
+	e.buf = append(e.buf, FormatVersion, flags)
+	if opts.Fingerprint {
+		fp := Fingerprint(ps...)
+		for i := 0; i < 8; i++ {
+			e.buf = append(e.buf, byte(fp>>(8*i)))
+		}
+	}
+	if flags&flagDictionary != 0 {
+		for i := 0; i < 4; i++ {
+			e.buf = append(e.buf, byte(opts.Dictionary>>(8*i)))
+		}
+	}
+	e.encLength(len(payload))
+	e.buf = append(e.buf, payload...)
+	if opts.Checksum {
+		sum := crc32.Checksum(e.buf[start:], castagnoli)
+		e.buf = append(e.buf, byte(sum), byte(sum>>8), byte(sum>>16), byte(sum>>24))
+	}
+	return e.buf
+}
+
+// UnmarshalEnvelope decodes an envelope written by MarshalEnvelope into the values
+// pointed to by is, and returns the length of the envelope, so that buf[n:] starts
+// after it. Besides the errors of ReadHeader and UnmarshalChecked, it fails with
+// ErrChecksum if the envelope has a checksum that does not match, ErrFingerprint if
+// it records a fingerprint other than that of is, if the payload cannot be
+// decompressed, with ErrTooLarge if it decompresses to more than
+// DefaultMaxDecompressedLen bytes, and if it does not hold exactly the encoding of is.
+func UnmarshalEnvelope(buf []byte, is ...any) (int, error) {
+	return UnmarshalEnvelopeWith(DecodeOptions{}, buf, is...)
+}
+
+// UnmarshalEnvelopeWith is UnmarshalEnvelope with the restrictions of opts.
+func UnmarshalEnvelopeWith(opts DecodeOptions, buf []byte, is ...any) (int, error) {
+	h, err := ReadHeader(buf)
+	if err != nil {
+		return 0, err
+	}
+	if err := opts.check(h); err != nil {
+		return 0, err
+	}
+	if h.PayloadLen > len(buf)-h.Len-h.trailerLen() {
+		return 0, fmt.Errorf("gotiny: envelope payload: %w", io.ErrUnexpectedEOF)
+	}
+	end := h.Len + h.PayloadLen
+	if h.HasChecksum && !checksumValid(crc32.Checksum(buf[:end], castagnoli), buf[end:]) {
+		return 0, ErrChecksum
+	}
+	if err := decodePayload(opts, h, buf[h.Len:end:end], is); err != nil {
+		return 0, err
+	}
+	return end + h.trailerLen(), nil
+}
+
+// trailerLen returns the length of what follows the payload of the envelope.
+func (h Header) trailerLen() int {
+	if h.HasChecksum {
+		return checksumLen
+	}
+	return 0
+}
+
+// checksumValid reports whether the checksum at the start of b is sum.
+func checksumValid(sum uint32, b []byte) bool {
+	return uint32(b[0])|uint32(b[1])<<8|uint32(b[2])<<16|uint32(b[3])<<24 == sum
+}
+
+// decodePayload decodes payload, the payload of an envelope with the header h,
+// into the values pointed to by is.
+func decodePayload(opts DecodeOptions, h Header, payload []byte, is []any) error {
+	if h.HasFingerprint && h.Fingerprint != Fingerprint(is...) {
+		return ErrFingerprint
+	}
+	if h.Compression != NoCompression {
+		var dict []byte
+		if h.Dictionary != 0 {
+			d := getDictionary(h.Dictionary)
+			if d == nil {
+				return fmt.Errorf("%w %d", ErrDictionary, h.Dictionary)
+			}
+			dict = d.data
+		}
+		var err error
+		if payload, err = decompress(h.Compression, dict, payload, opts.maxDecompressedLen()); err != nil {
+			return err
+		}
+	}
+	n, err := unmarshalChecked(payload, h.PackedFloats, is)
+	if err != nil {
+		return err
+	}
+	if n != len(payload) {
+		return fmt.Errorf("gotiny: %d trailing bytes in envelope payload", len(payload)-n)
+	}
+	return nil
+}
+
+// ReadHeader reads the header of the envelope at the start of buf. It returns an
+// error wrapping ErrNotEnvelope if buf does not start with an envelope, ErrVersion if
+// the envelope is of an unsupported format version, ErrOverflow if the payload and
+// checksum would be longer than the largest int, and io.ErrUnexpectedEOF if buf
+// ends within the header. It does not check that buf holds the whole payload.
+func ReadHeader(buf []byte) (h Header, err error) {
+	i := len(envelopeMagic)
+	if len(buf) < i+2 {
+		if len(buf) < i && string(buf) != envelopeMagic[:len(buf)] {
+			return h, ErrNotEnvelope
+		}
+		return h, fmt.Errorf("gotiny: envelope header: %w", io.ErrUnexpectedEOF)
+	}
+	if string(buf[:i]) != envelopeMagic {
+		return h, ErrNotEnvelope
+	}
+	h.Version, i = buf[i], i+1
+	if h.Version == 0 || h.Version > FormatVersion {
+		return h, fmt.Errorf("%w %d", ErrVersion, h.Version)
+	}
+	flags := buf[i]
+	i++
+	if flags&^knownFlags != 0 {
+		return h, fmt.Errorf("gotiny: unsupported envelope flags %#x", flags)
+	}
+	h.Compression = Compression(flags>>compressionShift) & compressionMask
+	h.HasChecksum = flags&flagChecksum != 0
+	h.PackedFloats = flags&flagPackedFloats != 0
+	if flags&flagFingerprint != 0 {
+		if len(buf) < i+8 {
+			return h, fmt.Errorf("gotiny: envelope header: %w", io.ErrUnexpectedEOF)
+		}
+		h.HasFingerprint = true
+		for j := 0; j < 8; j++ {
+			h.Fingerprint |= uint64(buf[i+j]) << (8 * j)
+		}
+		i += 8
+	}
+	if flags&flagDictionary != 0 {
+		if h.Compression != Flate && h.Compression != Zlib {
+			return h, fmt.Errorf("gotiny: preset dictionary with %v compression", h.Compression)
+		}
+		if len(buf) < i+4 {
+			return h, fmt.Errorf("gotiny: envelope header: %w", io.ErrUnexpectedEOF)
+		}
+		h.Dictionary = uint32(buf[i]) | uint32(buf[i+1])<<8 | uint32(buf[i+2])<<16 | uint32(buf[i+3])<<24
+		i += 4
+	}
+	// decode the length from a copy padded with zeros, which end any varint,
+	// so that a truncated one is detected instead of read past the end
+	var tmp [9]byte
+	copy(tmp[:], buf[i:])
+	d := Decoder{buf: tmp[:]}
+	l := d.decUint64()
+	if d.index > len(buf)-i {
+		return h, fmt.Errorf("gotiny: envelope header: %w", io.ErrUnexpectedEOF)
+	}
+	// the length of the payload and the checksum after it must fit in an int
+	if l > uint64(math.MaxInt-h.trailerLen()) {
+		return h, overflowError("int", l)
+	}
+	h.PayloadLen, h.Len = int(l), i+d.index
+	return h, nil
+}
+
+// Fingerprint returns a hash of the structure of the types pointed to by ps, which
+// changes when they change in a way that changes their encoding. It covers the
+// kinds of the types, the lengths of arrays, and the names and types of struct
+// fields in their encoding order, but not the names of the types themselves.
+// Types with their own encoding, such as those implementing Serializer, are
+// identified by the names GetNameByType returns for them.
+func Fingerprint(ps ...any) uint64 {
+	if len(ps) == 1 {
+		return getFingerprint(reflect.TypeOf(ps[0]).Elem())
+	}
+	h := fnv.New64a()
+	for _, p := range ps {
+		fp := getFingerprint(reflect.TypeOf(p).Elem())
+		h.Write([]byte{byte(fp), byte(fp >> 8), byte(fp >> 16), byte(fp >> 24),
+			byte(fp >> 32), byte(fp >> 40), byte(fp >> 48), byte(fp >> 56)})
+	}
+	return h.Sum64()
+}
+
+var (
+	rt2fingerprint  = map[reflect.Type]uint64{}
+	fingerprintLock sync.Mutex
+	fingerprintSnap snapshot[reflect.Type, uint64]
+)
+
+// getFingerprint retrieves or computes the fingerprint of rt.
+func getFingerprint(rt reflect.Type) uint64 {
+	if fp, ok := fingerprintSnap.load()[rt]; ok {
+		return fp
+	}
+	var b strings.Builder
+	describeType(&b, rt, map[reflect.Type]int{})
+	h := fnv.New64a()
+	h.Write([]byte(b.String()))
+	fp := h.Sum64()
+	fingerprintLock.Lock()
+	defer fingerprintLock.Unlock()
+	rt2fingerprint[rt] = fp
+	fingerprintSnap.store(rt2fingerprint)
+	return fp
+}
+
+// describeType writes the structure of rt that its encoding depends on to b.
+// A named type met again, such as one that contains itself, is written as a
+// reference to where it was met first, numbered in the order of first meeting.
+func describeType(b *strings.Builder, rt reflect.Type, seen map[reflect.Type]int) {
+	if rt == nil {
+		b.WriteString("nil")
+		return
+	}
+	if i, ok := seen[rt]; ok {
+		b.WriteString("#" + strconv.Itoa(i))
+		return
+	}
+	if rt.Name() != "" { // only named types can contain themselves
+		seen[rt] = len(seen)
+	}
+	if rt == reflect.TypeFor[time.Time]() {
+		b.WriteString("time")
+		return
+	}
+	if engine, _ := implementOtherSerializer(rt); engine != nil {
+		b.WriteString("opaque " + GetNameByType(rt))
+		return
+	}
+	if isPOD(rt) {
+		b.WriteString("pod ")
+	}
+	if et := rawElem(rt); et != nil {
+		b.WriteString("raw ")
+		describeType(b, et, seen)
+		return
+	}
+	switch rt.Kind() {
+	case reflect.Ptr:
+		b.WriteString("*")
+		describeType(b, rt.Elem(), seen)
+	case reflect.Array:
+		b.WriteString("[" + strconv.Itoa(rt.Len()) + "]")
+		describeType(b, rt.Elem(), seen)
+	case reflect.Slice:
+		b.WriteString("[]")
+		describeType(b, rt.Elem(), seen)
+	case reflect.Map:
+		b.WriteString("map[")
+		describeType(b, rt.Key(), seen)
+		b.WriteString("]")
+		describeType(b, rt.Elem(), seen)
+	case reflect.Struct:
+		fields, _, paths := getFieldPath(rt, 0, "")
+		b.WriteString("struct{")
+		for i, ft := range fields {
+			b.WriteString(paths[i] + " ")
+			describeType(b, ft, seen)
+			b.WriteString(";")
+		}
+		b.WriteString("}")
+	case reflect.Interface:
+		b.WriteString("interface")
+	default:
+		b.WriteString(rt.Kind().String())
+	}
+}
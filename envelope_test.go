@@ -0,0 +1,183 @@
+package gotiny
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+type envelopeNode struct {
+	Name string
+	Next *envelopeNode
+	Bin  goldenBin
+}
+
+func TestEnvelope(t *testing.T) {
+	if got, want := MarshalEnvelope(EnvelopeOptions{}, ptr(int8(-1))), []byte("gtny\x01\x00\x01\xff"); !bytes.Equal(got, want) {
+		t.Errorf("got %x, want %x", got, want)
+	}
+
+	src := envelopeNode{Name: "a", Next: &envelopeNode{Name: "b"}, Bin: goldenBin{7}}
+	for _, opts := range []EnvelopeOptions{{}, {Fingerprint: true}} {
+		buf := MarshalEnvelope(opts, &src, ptr("tail"))
+		buf = append(buf, "next"...)
+		h, err := ReadHeader(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.Version != FormatVersion || h.HasFingerprint != opts.Fingerprint || h.Len+h.PayloadLen != len(buf)-4 {
+			t.Errorf("%+v: read header %+v of %d bytes", opts, h, len(buf))
+		}
+		if opts.Fingerprint && h.Fingerprint != Fingerprint(&src, ptr("")) {
+			t.Errorf("%+v: fingerprint %x, want %x", opts, h.Fingerprint, Fingerprint(&src, ptr("")))
+		}
+		var dst envelopeNode
+		var tail string
+		n, err := UnmarshalEnvelope(buf, &dst, &tail)
+		if err != nil || n != len(buf)-4 || !reflect.DeepEqual(dst, src) || tail != "tail" {
+			t.Errorf("%+v: decoded %+v, %q from %d bytes, %v", opts, dst, tail, n, err)
+		}
+	}
+}
+
+func TestEnvelopeErrors(t *testing.T) {
+	valid := MarshalEnvelope(EnvelopeOptions{Fingerprint: true}, ptr("value"))
+	tests := []struct {
+		name string
+		buf  []byte
+		dst  any
+		want error
+	}{
+		{"empty", nil, new(string), io.ErrUnexpectedEOF},
+		{"short magic", []byte("gt"), new(string), io.ErrUnexpectedEOF},
+		{"garbage", []byte("not gotiny"), new(string), ErrNotEnvelope},
+		{"plain encoding", Marshal(ptr("value")), new(string), ErrNotEnvelope},
+		{"version", append([]byte("gtny\x02"), valid[5:]...), new(string), ErrVersion},
+		{"fingerprint", valid, new([]byte), ErrFingerprint},
+		{"truncated header", valid[:10], new(string), io.ErrUnexpectedEOF},
+		{"truncated payload", valid[:len(valid)-1], new(string), io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		if _, err := UnmarshalEnvelope(tt.buf, tt.dst); !errors.Is(err, tt.want) {
+			t.Errorf("%s: got error %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	flags := append([]byte(nil), valid...)
+	flags[5] |= 0x80
+	if _, err := UnmarshalEnvelope(flags, new(string)); err == nil {
+		t.Error("unknown flags: no error")
+	}
+	var huge Encoder // a checksummed payload whose length plus the checksum overflows an int
+	huge.buf = []byte("gtny\x01\x10")
+	huge.encUint64(1<<63 - 2)
+	if _, err := UnmarshalEnvelope(huge.buf, new(string)); !errors.Is(err, ErrOverflow) {
+		t.Errorf("huge length: got error %v, want ErrOverflow", err)
+	}
+	if err := NewEnvelopeReader(bytes.NewReader(huge.buf)).Decode(new(string)); !errors.Is(err, ErrOverflow) {
+		t.Errorf("huge length in a stream: got error %v, want ErrOverflow", err)
+	}
+
+	long := MarshalEnvelope(EnvelopeOptions{}, ptr(int8(1)))
+	long[6]++ // the payload length
+	if _, err := UnmarshalEnvelope(append(long, 0), new(int8)); err == nil {
+		t.Error("trailing payload bytes: no error")
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	type point struct{ X, Y int32 }
+	type renamed struct{ X, Y int32 }
+	type swapped struct{ Y, X int32 }
+	type wider struct{ X, Y int64 }
+	fp := Fingerprint(new(point))
+	if Fingerprint(new(renamed)) != fp {
+		t.Error("the fingerprint depends on the name of the type")
+	}
+	for _, p := range []any{new(swapped), new(wider), new([]point), new(envelopeNode)} {
+		if Fingerprint(p) == fp {
+			t.Errorf("%T has the fingerprint of point", p)
+		}
+	}
+	if Fingerprint(new(point), new(int)) == Fingerprint(new(int), new(point)) {
+		t.Error("the fingerprint does not depend on the order of the types")
+	}
+	if Fingerprint(new(envelopeNode)) != Fingerprint(new(envelopeNode)) {
+		t.Error("the fingerprint of a recursive type is not stable")
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	if got, want := MarshalEnvelope(EnvelopeOptions{Checksum: true}, ptr(int8(-1))), []byte("gtny\x01\x10\x01\xff"); !bytes.HasPrefix(got, want) || len(got) != len(want)+4 {
+		t.Errorf("got %x, want %x and a checksum", got, want)
+	}
+	src := repetitive()
+	for _, opts := range []EnvelopeOptions{{Checksum: true}, {Checksum: true, Compression: Gzip, Fingerprint: true}} {
+		buf := MarshalEnvelope(opts, &src)
+		h, err := ReadHeader(buf)
+		if err != nil || !h.HasChecksum || h.Len+h.PayloadLen+4 != len(buf) {
+			t.Fatalf("%+v: header %+v of %d bytes, %v", opts, h, len(buf), err)
+		}
+		var dst compressTyp
+		if n, err := UnmarshalEnvelope(append(buf, "next"...), &dst); err != nil || n != len(buf) || !reflect.DeepEqual(dst, src) {
+			t.Errorf("%+v: decoded %d of %d bytes, %v", opts, n, len(buf), err)
+		}
+
+		// the last byte of the length, which shortens the payload, a byte of the
+		// payload and a byte of the checksum
+		for _, i := range []int{h.Len - 1, h.Len + h.PayloadLen/2, len(buf) - 1} {
+			bad := bytes.Clone(buf)
+			bad[i]--
+			if _, err := UnmarshalEnvelope(bad, &dst); !errors.Is(err, ErrChecksum) {
+				t.Errorf("%+v: byte %d flipped: got error %v", opts, i, err)
+			}
+			if err := NewEnvelopeReader(bytes.NewReader(bad)).Decode(&dst); !errors.Is(err, ErrChecksum) {
+				t.Errorf("%+v: byte %d flipped in a stream: got error %v", opts, i, err)
+			}
+		}
+		if _, err := UnmarshalEnvelope(buf[:len(buf)-1], &dst); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("%+v: truncated checksum: got error %v", opts, err)
+		}
+
+		// a cleared checksum flag is only detected by readers that require one
+		cleared := bytes.Clone(buf)
+		cleared[5] &^= flagChecksum
+		require := DecodeOptions{RequireChecksum: true}
+		if _, err := UnmarshalEnvelopeWith(require, cleared, &dst); !errors.Is(err, ErrChecksum) {
+			t.Errorf("%+v: checksum flag cleared: got error %v", opts, err)
+		}
+		r := NewEnvelopeReader(bytes.NewReader(cleared))
+		r.SetDecodeOptions(require)
+		if err := r.Decode(&dst); !errors.Is(err, ErrChecksum) {
+			t.Errorf("%+v: checksum flag cleared in a stream: got error %v", opts, err)
+		}
+		if _, err := UnmarshalEnvelopeWith(require, buf, &dst); err != nil {
+			t.Errorf("%+v: with a checksum required: %v", opts, err)
+		}
+	}
+
+	var stream bytes.Buffer
+	w := NewEnvelopeWriter(&stream, EnvelopeOptions{Checksum: true, Compression: Flate})
+	for i := 0; i < 2; i++ {
+		if err := w.Encode(&src, &i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	whole := stream.Bytes()
+	r := NewEnvelopeReader(bytes.NewReader(whole))
+	for i := 0; i < 2; i++ {
+		var dst compressTyp
+		var n int
+		if err := r.Decode(&dst, &n); err != nil || n != i || !reflect.DeepEqual(dst, src) {
+			t.Fatalf("envelope %d: decoded %d, %v", i, n, err)
+		}
+	}
+	if err := r.Decode(new(int)); err != io.EOF {
+		t.Errorf("at the end: got error %v, want io.EOF", err)
+	}
+	if err := NewEnvelopeReader(bytes.NewReader(whole[:len(whole)/2-1])).Decode(&compressTyp{}, new(int)); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("truncated checksum in a stream: got error %v", err)
+	}
+}
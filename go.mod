@@ -1,4 +1,4 @@
 module github.com/niubaoshu/gotiny
 
-go 1.18
+go 1.22
 
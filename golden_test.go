@@ -0,0 +1,254 @@
+package gotiny
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden vectors in testdata")
+
+// goldenPath holds the encodings the format described in SPEC.md produces for the
+// vectors below. TestGolden fails whenever one of them changes; if the change is
+// intended, the format version in SPEC.md must be increased and the file rewritten
+// with go test -run TestGolden -update.
+var goldenPath = filepath.Join("testdata", "golden.txt")
+
+type (
+	goldenPoint struct {
+		X, Y int32
+	}
+	goldenInner struct {
+		S string
+		B bool
+	}
+	goldenStruct struct {
+		A       bool
+		N       uint8
+		Inner   goldenInner
+		P       *goldenInner
+		skipped int `gotiny:"-"`
+		hidden  int16
+		goldenPoint
+		Z bool
+	}
+	goldenPOD struct {
+		_    struct{} `gotiny:"pod"`
+		X, Y float32
+		N    int16
+	}
+	goldenBin struct{ v uint16 }
+	goldenSer struct{ v uint16 }
+	goldenRaw struct {
+		A bool
+		R Raw[goldenInner]
+		B bool
+	}
+)
+
+func (b goldenBin) MarshalBinary() ([]byte, error) { return []byte{byte(b.v >> 8), byte(b.v)}, nil }
+func (b *goldenBin) UnmarshalBinary(buf []byte) error {
+	b.v = uint16(buf[0])<<8 | uint16(buf[1])
+	return nil
+}
+
+func (s *goldenSer) GotinyEncode(buf []byte) []byte { return append(buf, byte(s.v>>8), byte(s.v)) }
+func (s *goldenSer) GotinyDecode(buf []byte) int {
+	s.v = uint16(buf[0])<<8 | uint16(buf[1])
+	return 2
+}
+
+type goldenVector struct {
+	name   string
+	values []any // pointers to the values encoded together by one Marshal call
+	packed bool  // encode and decode with packed floats
+	wide   bool  // only tested where int is 64 bits wide
+}
+
+func gv[T any](name string, v T) goldenVector { return goldenVector{name: name, values: []any{&v}} }
+
+func goldenVectors() []goldenVector {
+	one := 1
+	pOne := &one
+	var raw Raw[goldenInner]
+	raw.Set(goldenInner{"r", true})
+	bit40 := uint64(1) << 40
+	return []goldenVector{
+		gv("bool/false", false),
+		gv("bool/true", true),
+		gv("bool/packed", [10]bool{true, false, true, true, false, false, false, true, false, true}),
+		{name: "bool/shared-across-values", values: []any{ptr(true), ptr(int8(-1)), ptr(false), ptr(true)}},
+
+		gv("int8/min", int8(math.MinInt8)),
+		gv("int8/max", int8(math.MaxInt8)),
+		gv("uint8/max", uint8(math.MaxUint8)),
+		gv("int16/-1", int16(-1)),
+		gv("int16/min", int16(math.MinInt16)),
+		gv("int16/max", int16(math.MaxInt16)),
+		gv("uint16/127", uint16(127)),
+		gv("uint16/128", uint16(128)),
+		gv("uint16/16384", uint16(16384)),
+		gv("uint16/max", uint16(math.MaxUint16)),
+		gv("int32/min", int32(math.MinInt32)),
+		gv("int32/max", int32(math.MaxInt32)),
+		gv("uint32/2^28-1", uint32(1<<28-1)),
+		gv("uint32/2^28", uint32(1<<28)),
+		gv("uint32/max", uint32(math.MaxUint32)),
+		gv("int64/min", int64(math.MinInt64)),
+		gv("int64/max", int64(math.MaxInt64)),
+		gv("uint64/2^56-1", uint64(1<<56-1)),
+		gv("uint64/2^56", uint64(1<<56)),
+		gv("uint64/2^63", uint64(1<<63)),
+		gv("uint64/max", uint64(math.MaxUint64)),
+		gv("int/-3", -3),
+		gv("uint/300", uint(300)),
+		gv("uintptr/2^31", uintptr(1<<31)),
+		{name: "uintptr/2^40", values: []any{ptr(uintptr(bit40))}, wide: true},
+
+		gv("float32/0", float32(0)),
+		gv("float32/1", float32(1)),
+		gv("float32/-2.5", float32(-2.5)),
+		gv("float32/+inf", float32(math.Inf(1))),
+		gv("float64/0", 0.0),
+		gv("float64/1", 1.0),
+		gv("float64/0.1", 0.1),
+		gv("float64/-inf", math.Inf(-1)),
+		gv("float64/denormal", math.SmallestNonzeroFloat64),
+		gv("complex64", complex64(complex(1, -2))),
+		gv("complex128", complex(1.5, -0.25)),
+
+		gv("string/empty", ""),
+		gv("string/ascii", "gotiny"),
+		gv("string/utf8", "héllo, 世界"),
+		gv("string/200", strings.Repeat("x", 200)),
+		gv("bytes/nil", []byte(nil)),
+		gv("bytes/empty", []byte{}),
+		gv("bytes/data", []byte{0, 1, 255}),
+
+		gv("pointer/nil", (*int)(nil)),
+		gv("pointer/int", pOne),
+		gv("pointer/pointer", &pOne),
+		gv("array/int16", [3]int16{-1, 0, 1000}),
+		gv("slice/nil", []string(nil)),
+		gv("slice/empty", []string{}),
+		gv("slice/strings", []string{"a", "", "bc"}),
+		gv("slice/bools", []bool{true, true, false}),
+		gv("map/nil", map[string]int(nil)),
+		gv("map/empty", map[string]int{}),
+		gv("map/string-int", map[string]int{"k": -1}),
+		gv("map/int-bool", map[int16]bool{7: true}),
+
+		gv("struct", goldenStruct{A: true, N: 9, Inner: goldenInner{"in", true}, P: &goldenInner{"p", false},
+			hidden: -2, goldenPoint: goldenPoint{3, -4}, Z: true}),
+		gv("struct/empty", struct{}{}),
+		gv("interface/nil", any(nil)),
+		gv("interface/int32", any(int32(7))),
+		gv("interface/struct", any(goldenPoint{1, 2})),
+		gv("time", time.Unix(1700000000, 5)),
+		gv("duration", -time.Second),
+
+		gv("binarymarshaler", goldenBin{0x1234}),
+		gv("serializer", goldenSer{0x1234}),
+		gv("pod", goldenPOD{X: 1, Y: -2, N: -3}),
+		gv("raw", goldenRaw{A: true, R: raw, B: true}),
+		{name: "packed/float32", values: []any{ptr([]float32{1, -2.5})}, packed: true},
+		{name: "packed/float64", values: []any{ptr([]float64{0.1})}, packed: true},
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestGolden(t *testing.T) {
+	if *update && strconv.IntSize != 64 {
+		t.Fatal("the vectors must be rewritten where int is 64 bits wide")
+	}
+	var got bytes.Buffer
+	got.WriteString("# Encodings of the vectors in golden_test.go, see SPEC.md.\n")
+	narrow := map[string]bool{}
+	for _, v := range goldenVectors() {
+		if v.wide && strconv.IntSize != 64 {
+			narrow[v.name] = true
+			continue
+		}
+		buf, decoded := goldenRoundTrip(t, v)
+		if !reflect.DeepEqual(decoded, v.values) {
+			t.Errorf("%s: decoding %x does not restore the value", v.name, buf)
+		}
+		fmt.Fprintf(&got, "%s %x\n", v.name, buf)
+	}
+	if *update {
+		if err := os.WriteFile(goldenPath, got.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := readGolden(goldenPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotVectors, _ := readGoldenFrom(&got)
+	for name, hex := range gotVectors {
+		if w, ok := want[name]; !ok {
+			t.Errorf("%s: missing from %s; run go test -run TestGolden -update", name, goldenPath)
+		} else if hex != w {
+			t.Errorf("%s: encoding changed\ngot  %s\nwant %s", name, hex, w)
+		}
+	}
+	for name := range want {
+		if _, ok := gotVectors[name]; !ok && !narrow[name] {
+			t.Errorf("%s: in %s but no longer tested", name, goldenPath)
+		}
+	}
+}
+
+// goldenRoundTrip encodes the values of v and decodes them into new values.
+func goldenRoundTrip(t *testing.T, v goldenVector) ([]byte, []any) {
+	e := NewEncoderWithPtr(v.values...)
+	e.SetPackedFloats(v.packed)
+	buf := e.Encode(v.values...)
+	decoded := make([]any, len(v.values))
+	for i, p := range v.values {
+		decoded[i] = reflect.New(reflect.TypeOf(p).Elem()).Interface()
+	}
+	d := NewDecoderWithPtr(decoded...)
+	d.SetPackedFloats(v.packed)
+	if n := d.Decode(buf, decoded...); n != len(buf) {
+		t.Errorf("%s: decoded %d of %d bytes", v.name, n, len(buf))
+	}
+	return buf, decoded
+}
+
+func readGolden(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readGoldenFrom(f)
+}
+
+// readGoldenFrom parses lines of a vector name and its encoding in hex.
+func readGoldenFrom(r io.Reader) (map[string]string, error) {
+	vectors := map[string]string{}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, hex, _ := strings.Cut(line, " ")
+		vectors[name] = hex
+	}
+	return vectors, sc.Err()
+}
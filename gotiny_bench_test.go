@@ -181,3 +181,156 @@ func BenchmarkDecodeBool(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkSkip(b *testing.B) {
+	rt := reflect.TypeOf(value).Elem()
+	for i := 0; i < b.N; i++ {
+		d.Skip(buf, rt)
+	}
+}
+
+func BenchmarkMarshalAppend(b *testing.B) {
+	b.ReportAllocs()
+	buf := make([]byte, 0, 1<<16)
+	v := genBase()
+	for i := 0; i < b.N; i++ {
+		buf = MarshalAppend(buf[:0], &v)
+	}
+}
+
+func BenchmarkPOD(b *testing.B) {
+	type telemetry struct {
+		T   int64
+		Pos [3]float64
+		Mat [4][4]float32
+	}
+	type podTelemetry struct {
+		_ struct{} `gotiny:"pod"`
+		telemetry
+	}
+	v := make([]telemetry, 256)
+	for i := range v {
+		v[i] = telemetry{T: int64(i) << 40, Pos: [3]float64{1.5, float64(i), -3}}
+	}
+	pv := make([]podTelemetry, len(v))
+	for i := range pv {
+		pv[i].telemetry = v[i]
+	}
+	buf := make([]byte, 0, 1<<16)
+	b.Run("fields", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			buf = MarshalAppend(buf[:0], &v)
+		}
+	})
+	b.Run("pod", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			buf = MarshalAppend(buf[:0], &pv)
+		}
+	})
+}
+
+func BenchmarkNumericSlice(b *testing.B) {
+	v := make([]float64, 4096)
+	for i := range v {
+		v[i] = rand.NormFloat64()
+	}
+	var r []float64
+	for _, packed := range []bool{false, true} {
+		name := "varint"
+		if packed {
+			name = "packed"
+		}
+		e, d := NewEncoderWithPtr(&v), NewDecoderWithPtr(&r)
+		e.SetPackedFloats(packed)
+		d.SetPackedFloats(packed)
+		e.AppendTo(make([]byte, 0, 1<<16))
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				d.Decode(e.Encode(&v), &r)
+			}
+		})
+	}
+}
+
+func BenchmarkMapStringInt(b *testing.B) {
+	b.ReportAllocs()
+	m := make(map[string]int, 64)
+	for i := 0; i < 64; i++ {
+		m[randString(12)] = rand.Int()
+	}
+	buf := make([]byte, 0, 1<<12)
+	var r map[string]int
+	for i := 0; i < b.N; i++ {
+		buf = MarshalAppend(buf[:0], &m)
+		Unmarshal(buf, &r)
+	}
+}
+
+func BenchmarkParallelMarshal(b *testing.B) {
+	v := genBase()
+	b.RunParallel(func(pb *testing.PB) {
+		buf := make([]byte, 0, 1<<12)
+		for pb.Next() {
+			buf = MarshalAppend(buf[:0], &v)
+		}
+	})
+}
+
+func BenchmarkParallelUnmarshal(b *testing.B) {
+	v := genBase()
+	buf := Marshal(&v)
+	b.RunParallel(func(pb *testing.PB) {
+		var r baseTyp
+		for pb.Next() {
+			Unmarshal(buf, &r)
+		}
+	})
+}
+
+func BenchmarkParallelInterface(b *testing.B) {
+	v := []any{1, "a", genBase(), []int{1, 2, 3}, map[string]int{"a": 1}}
+	buf := Marshal(&v)
+	b.RunParallel(func(pb *testing.PB) {
+		var r []any
+		buf := append([]byte(nil), buf...)
+		for pb.Next() {
+			buf = MarshalAppend(buf[:0], &v)
+			Unmarshal(buf, &r)
+		}
+	})
+}
+
+// BenchmarkMarshalElements measures the element loops of the slice, array and map
+// engines, which plain Marshal runs without the flushing of streaming encoders.
+func BenchmarkMarshalElements(b *testing.B) {
+	type point struct {
+		X, Y int32
+		Name string
+	}
+	s := make([]point, 1024)
+	var a [64]point
+	m := make(map[int32]point, 64)
+	for i := range s {
+		s[i] = point{X: int32(i), Y: -int32(i), Name: randString(8)}
+		if i < len(a) {
+			a[i] = s[i]
+			m[int32(i)] = s[i]
+		}
+	}
+	buf := make([]byte, 0, 1<<16)
+	b.Run("slice", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			buf = MarshalAppend(buf[:0], &s)
+		}
+	})
+	b.Run("array", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			buf = MarshalAppend(buf[:0], &a)
+		}
+	})
+	b.Run("map", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			buf = MarshalAppend(buf[:0], &m)
+		}
+	})
+}
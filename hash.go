@@ -0,0 +1,38 @@
+package gotiny
+
+import (
+	"hash"
+	"hash/fnv"
+	"reflect"
+)
+
+// Hash writes the canonical encoding of v to h without building the whole
+// encoded byte slice first, although a long string or byte slice is buffered
+// whole. The canonical encoding is the regular encoding
+// with map entries sorted by their encoded keys, so equal values always
+// produce the same bytes and therefore the same hash. Raw values are decoded
+// and encoded again to sort the maps they hold. The bytes written by Serializer,
+// encoding.BinaryMarshaler and gob.GobEncoder methods are hashed as they are, so
+// types whose methods encode maps in iteration order do not hash consistently.
+//
+// Unlike Marshal, v is the value to be hashed and not a pointer to it.
+// The returned error is the first error returned by h.Write, if any.
+func Hash(v any, h hash.Hash) error {
+	e := Encoder{
+		buf:       make([]byte, 0, 2*flushSize),
+		canonical: true,
+		w:         h,
+	}
+	getEncEngine(reflect.TypeOf(v))(&e, getUnsafePointer(reflect.ValueOf(v)))
+	e.boolBit = 0 // the last bool byte is complete now
+	e.flush()
+	return e.err
+}
+
+// Sum64 returns the 64-bit FNV-1a hash of the canonical encoding of v.
+// See Hash for details.
+func Sum64(v any) uint64 {
+	h := fnv.New64a()
+	Hash(v, h)
+	return h.Sum64()
+}
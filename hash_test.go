@@ -0,0 +1,75 @@
+package gotiny
+
+import (
+	"bytes"
+	"hash/fnv"
+	"math/rand"
+	"testing"
+)
+
+func TestHashMapOrder(t *testing.T) {
+	keys := rand.Perm(1000)
+	m1 := map[int]map[string]bool{}
+	m2 := map[int]map[string]bool{}
+	for _, k := range keys {
+		m1[k] = map[string]bool{randString(5): k%2 == 0, "x": true}
+	}
+	for i := len(keys) - 1; i >= 0; i-- {
+		k := keys[i]
+		m2[k] = map[string]bool{}
+		for s, b := range m1[k] {
+			m2[k][s] = b
+		}
+	}
+	if Sum64(m1) != Sum64(m2) {
+		t.Fatal("equal maps have different hashes")
+	}
+	m2[keys[0]]["x"] = false
+	if Sum64(m1) == Sum64(m2) {
+		t.Fatal("different maps have the same hash")
+	}
+}
+
+func TestHashMatchesMarshal(t *testing.T) {
+	type item struct {
+		Name  string
+		On    bool
+		Count int
+		Off   bool
+	}
+	v := make([]item, 3000)
+	for i := range v {
+		v[i] = item{Name: randString(rand.Intn(20)), On: rand.Intn(2) == 0, Count: rand.Int(), Off: rand.Intn(2) == 0}
+	}
+	h := fnv.New64a()
+	if err := Hash(v, h); err != nil {
+		t.Fatal(err)
+	}
+	want := fnv.New64a()
+	want.Write(Marshal(&v))
+	if h.Sum64() != want.Sum64() {
+		t.Fatal("streamed hash differs from the hash of the encoded bytes")
+	}
+}
+
+func TestHashRaw(t *testing.T) {
+	m := map[int]string{}
+	for i := range 100 {
+		m[i] = randString(3)
+	}
+	// Marshal encodes maps in iteration order, so the bytes of the Raws differ
+	type holder struct{ R Raw[map[int]string] }
+	var a, b holder
+	for a.R.Bytes() == nil || bytes.Equal(a.R.Bytes(), b.R.Bytes()) {
+		a.R.Set(m)
+		b.R.Set(m)
+	}
+	if Sum64(a) != Sum64(b) {
+		t.Fatal("Raws of equal maps have different hashes")
+	}
+	m[0] += "x"
+	b.R.Set(m)
+	if Sum64(a) == Sum64(b) {
+		t.Fatal("Raws of different maps have the same hash")
+	}
+}
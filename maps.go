@@ -0,0 +1,203 @@
+package gotiny
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// The engines below handle the most common map types with Go map operations
+// instead of reflect.MapIter and reflect.Value.SetMapIndex. Their keys and values
+// are encoded by the same base functions as anywhere else, so the output is the same.
+
+var efaceType = reflect.TypeFor[any]()
+
+func init() {
+	for rt, engs := range map[reflect.Type]struct {
+		enc encEng
+		dec decEng
+	}{
+		reflect.TypeFor[map[string]string](): {encMapStringString, decMapStringString},
+		reflect.TypeFor[map[string]int]():    {encMapStringInt, decMapStringInt},
+		reflect.TypeFor[map[string]any]():    {encMapStringAny, decMapStringAny},
+		reflect.TypeFor[map[int]int]():       {encMapIntInt, decMapIntInt},
+		reflect.TypeFor[map[int]string]():    {encMapIntString, decMapIntString},
+		reflect.TypeFor[map[int]any]():       {encMapIntAny, decMapIntAny},
+	} {
+		rt2encEng[rt] = engs.enc
+		rt2decEng[rt] = engs.dec
+	}
+}
+
+func encMapStringString(e *Encoder, p unsafe.Pointer) {
+	m := *(*map[string]string)(p)
+	if e.encMapHeader(m, encString, encString) {
+		for k, v := range m {
+			encString(e, unsafe.Pointer(&k))
+			encString(e, unsafe.Pointer(&v))
+		}
+	}
+}
+func encMapStringInt(e *Encoder, p unsafe.Pointer) {
+	m := *(*map[string]int)(p)
+	if e.encMapHeader(m, encString, encInt) {
+		for k, v := range m {
+			encString(e, unsafe.Pointer(&k))
+			encInt(e, unsafe.Pointer(&v))
+		}
+	}
+}
+func encMapStringAny(e *Encoder, p unsafe.Pointer) {
+	m := *(*map[string]any)(p)
+	if e.encMapHeader(m, encString, encEface) {
+		for k, v := range m {
+			encString(e, unsafe.Pointer(&k))
+			encEface(e, unsafe.Pointer(&v))
+		}
+	}
+}
+func encMapIntInt(e *Encoder, p unsafe.Pointer) {
+	m := *(*map[int]int)(p)
+	if e.encMapHeader(m, encInt, encInt) {
+		for k, v := range m {
+			encInt(e, unsafe.Pointer(&k))
+			encInt(e, unsafe.Pointer(&v))
+		}
+	}
+}
+func encMapIntString(e *Encoder, p unsafe.Pointer) {
+	m := *(*map[int]string)(p)
+	if e.encMapHeader(m, encInt, encString) {
+		for k, v := range m {
+			encInt(e, unsafe.Pointer(&k))
+			encString(e, unsafe.Pointer(&v))
+		}
+	}
+}
+func encMapIntAny(e *Encoder, p unsafe.Pointer) {
+	m := *(*map[int]any)(p)
+	if e.encMapHeader(m, encInt, encEface) {
+		for k, v := range m {
+			encInt(e, unsafe.Pointer(&k))
+			encEface(e, unsafe.Pointer(&v))
+		}
+	}
+}
+
+// encMapHeader encodes whether the map m is nil and its length. It returns true
+// if the caller still has to encode the entries, which is not the case for nil maps
+// and for canonical encoders, whose entries are encoded here in sorted order.
+func (e *Encoder) encMapHeader(m any, kEng, vEng encEng) bool {
+	v := reflect.ValueOf(m)
+	isNotNil := !v.IsNil()
+	e.encIsNotNil(isNotNil)
+	if !isNotNil {
+		return false
+	}
+	e.encLength(v.Len())
+	if e.canonical {
+		encSortedMap(e, v, kEng, vEng)
+		return false
+	}
+	return true
+}
+
+func decMapStringString(d *Decoder, p unsafe.Pointer) {
+	m := (*map[string]string)(p)
+	if l, ok := d.decMapHeader(p); ok {
+		if *m == nil {
+			*m = make(map[string]string, l)
+		}
+		for i := 0; i < l; i++ {
+			var k, v string
+			decString(d, unsafe.Pointer(&k))
+			decString(d, unsafe.Pointer(&v))
+			(*m)[k] = v
+		}
+	}
+}
+func decMapStringInt(d *Decoder, p unsafe.Pointer) {
+	m := (*map[string]int)(p)
+	if l, ok := d.decMapHeader(p); ok {
+		if *m == nil {
+			*m = make(map[string]int, l)
+		}
+		for i := 0; i < l; i++ {
+			var k string
+			var v int
+			decString(d, unsafe.Pointer(&k))
+			decInt(d, unsafe.Pointer(&v))
+			(*m)[k] = v
+		}
+	}
+}
+func decMapStringAny(d *Decoder, p unsafe.Pointer) {
+	m := (*map[string]any)(p)
+	if l, ok := d.decMapHeader(p); ok {
+		if *m == nil {
+			*m = make(map[string]any, l)
+		}
+		for i := 0; i < l; i++ {
+			var k string
+			var v any
+			decString(d, unsafe.Pointer(&k))
+			decEface(d, unsafe.Pointer(&v))
+			(*m)[k] = v
+		}
+	}
+}
+func decMapIntInt(d *Decoder, p unsafe.Pointer) {
+	m := (*map[int]int)(p)
+	if l, ok := d.decMapHeader(p); ok {
+		if *m == nil {
+			*m = make(map[int]int, l)
+		}
+		for i := 0; i < l; i++ {
+			var k, v int
+			decInt(d, unsafe.Pointer(&k))
+			decInt(d, unsafe.Pointer(&v))
+			(*m)[k] = v
+		}
+	}
+}
+func decMapIntString(d *Decoder, p unsafe.Pointer) {
+	m := (*map[int]string)(p)
+	if l, ok := d.decMapHeader(p); ok {
+		if *m == nil {
+			*m = make(map[int]string, l)
+		}
+		for i := 0; i < l; i++ {
+			var k int
+			var v string
+			decInt(d, unsafe.Pointer(&k))
+			decString(d, unsafe.Pointer(&v))
+			(*m)[k] = v
+		}
+	}
+}
+func decMapIntAny(d *Decoder, p unsafe.Pointer) {
+	m := (*map[int]any)(p)
+	if l, ok := d.decMapHeader(p); ok {
+		if *m == nil {
+			*m = make(map[int]any, l)
+		}
+		for i := 0; i < l; i++ {
+			var k int
+			var v any
+			decInt(d, unsafe.Pointer(&k))
+			decEface(d, unsafe.Pointer(&v))
+			(*m)[k] = v
+		}
+	}
+}
+
+// decMapHeader decodes whether a map is nil and its length. It sets the map at p
+// to nil if the encoded map is nil, and otherwise returns its length and true.
+func (d *Decoder) decMapHeader(p unsafe.Pointer) (int, bool) {
+	if d.decIsNotNil() {
+		return d.decLength(), true
+	}
+	if !isNil(p) {
+		*(*unsafe.Pointer)(p) = nil
+	}
+	return 0, false
+}
@@ -0,0 +1,93 @@
+package gotiny
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+type (
+	genericMapSS map[string]string
+	genericMapSI map[string]int
+	genericMapSA map[string]any
+	genericMapII map[int]int
+	genericMapIS map[int]string
+	genericMapIA map[int]any
+)
+
+func TestMaps(t *testing.T) {
+	for _, c := range []struct{ fast, generic any }{
+		{&map[string]string{"a": "b"}, &genericMapSS{"a": "b"}},
+		{&map[string]int{"a": -1}, &genericMapSI{"a": -1}},
+		{&map[string]any{"a": 1.5}, &genericMapSA{"a": 1.5}},
+		{&map[int]int{-1: 1 << 30}, &genericMapII{-1: 1 << 30}},
+		{&map[int]string{7: "x"}, &genericMapIS{7: "x"}},
+		{&map[int]any{7: []string{"x"}}, &genericMapIA{7: []string{"x"}}},
+		{new(map[string]string), new(genericMapSS)},
+		{&map[int]any{}, &genericMapIA{}},
+	} {
+		buf := Marshal(c.fast)
+		if want := Marshal(c.generic); !bytes.Equal(buf, want) {
+			t.Fatalf("%T: encoded %x, want %x", c.fast, buf, want)
+		}
+		r := reflect.New(reflect.TypeOf(c.fast).Elem())
+		if n := Unmarshal(buf, r.Interface()); n != len(buf) {
+			t.Fatalf("%T: decoded %d of %d bytes", c.fast, n, len(buf))
+		}
+		Assert(t, buf, reflect.ValueOf(c.fast).Elem().Interface(), r.Elem().Interface())
+		if Size(c.fast) != len(buf) {
+			t.Fatalf("%T: Size %d, encoded %d bytes", c.fast, Size(c.fast), len(buf))
+		}
+	}
+}
+
+func TestMapsCanonical(t *testing.T) {
+	m := map[string]any{}
+	for i := 0; i < 100; i++ {
+		m[randString(8)] = i
+	}
+	if Sum64(m) != Sum64(genericMapSA(m)) {
+		t.Fatal("specialized and generic engines hash differently")
+	}
+	mi := map[int]string{}
+	for i := 0; i < 100; i++ {
+		mi[i*7919] = randString(3)
+	}
+	a, b := Encoder{canonical: true}, Encoder{canonical: true}
+	encMapIntString(&a, reflect.ValueOf(&mi).UnsafePointer())
+	getEncEngine(reflect.TypeOf(genericMapIS{}))(&b, reflect.ValueOf(&mi).UnsafePointer())
+	if !bytes.Equal(a.buf, b.buf) {
+		t.Fatal("specialized and generic canonical encodings differ")
+	}
+}
+
+func TestMapsReuse(t *testing.T) {
+	v := map[int]any{1: "a", 2: 2}
+	buf := Marshal(&v)
+	r := map[int]any{3: "c"}
+	Unmarshal(buf, &r)
+	if len(r) != 3 || r[1] != "a" || r[2] != 2 || r[3] != "c" {
+		t.Fatalf("decoded %v", r)
+	}
+	var n map[int]any
+	Unmarshal(Marshal(&n), &r)
+	if r != nil {
+		t.Fatalf("decoded %v, want nil", r)
+	}
+}
+
+func TestMapsAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector makes sync.Pool drop encoders")
+	}
+	m := map[string]int{"a": 1, "b": 2, "c": 3}
+	g := map[[2]int]int16{{1, 2}: 3, {4, 5}: 6}
+	buf := make([]byte, 0, 1024)
+	if allocs := testing.AllocsPerRun(100, func() { buf = MarshalAppend(buf[:0], &m) }); allocs != 0 {
+		t.Fatalf("map[string]int: %v allocations per run", allocs)
+	}
+	// one allocation each for the reused key and value
+	if allocs := testing.AllocsPerRun(100, func() { buf = MarshalAppend(buf[:0], &g) }); allocs > 2 {
+		t.Fatalf("map[[2]int]int16: %v allocations per run", allocs)
+	}
+}
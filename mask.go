@@ -0,0 +1,69 @@
+package gotiny
+
+import (
+	"reflect"
+	"strings"
+)
+
+// FieldMask selects fields of a struct by name. A field mapped to nil is selected
+// as a whole, a field mapped to a non-nil FieldMask only has the fields selected
+// by that mask. Use NewFieldMask to build one from paths.
+type FieldMask map[string]FieldMask
+
+// NewFieldMask returns the FieldMask selecting the given dot separated field paths,
+// e.g. NewFieldMask("User.Name", "User.Address.City"). A path selects its field
+// as a whole, including all the fields inside it.
+func NewFieldMask(paths ...string) FieldMask {
+	mask := FieldMask{}
+	for _, path := range paths {
+		m := mask
+		names := strings.Split(path, ".")
+		for i, name := range names {
+			sub, has := m[name]
+			if i == len(names)-1 {
+				m[name] = nil
+				break
+			}
+			if has && sub == nil {
+				break // the whole field is already selected
+			}
+			if sub == nil {
+				sub = FieldMask{}
+				m[name] = sub
+			}
+			m = sub
+		}
+	}
+	return mask
+}
+
+/*
+EncodeMasked encodes only the fields selected by mask of the struct pointed to by v,
+which must be a pointer to a struct or to a pointer to one. Every field is preceded
+by a presence bit and selected fields are followed by their usual encoding, or by a
+masked encoding of their own if the mask selects fields inside them.
+EncodeMasked panics if the mask names a field that does not exist.
+*/
+func EncodeMasked(v any, mask FieldMask) []byte {
+	rt := reflect.TypeOf(v)
+	if rt.Kind() != reflect.Ptr {
+		panic("must a pointer type!")
+	}
+	e := Encoder{}
+	buildMaskedEncEngine(rt.Elem(), mask)(&e, reflect.ValueOf(v).UnsafePointer())
+	return e.buf
+}
+
+// DecodeMasked decodes the output of EncodeMasked into the value pointed to by v,
+// which must have the type passed to EncodeMasked. Only the fields present in buf
+// are written, all other fields of the value are left untouched.
+// It returns the number of bytes read from buf.
+func DecodeMasked(buf []byte, v any) int {
+	rt := reflect.TypeOf(v)
+	if rt.Kind() != reflect.Ptr {
+		panic("the argument must be a pointer type!")
+	}
+	d := Decoder{buf: buf}
+	getMaskedDecEngine(rt.Elem())(&d, reflect.ValueOf(v).UnsafePointer())
+	return d.index
+}
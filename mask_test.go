@@ -0,0 +1,77 @@
+package gotiny
+
+import (
+	"reflect"
+	"testing"
+)
+
+type maskAddress struct {
+	Street, City string
+}
+
+type maskUser struct {
+	Name    string
+	Age     int
+	Address *maskAddress
+	Tags    []string
+}
+
+type maskTyp struct {
+	ID   int
+	User maskUser
+	Next *maskTyp
+}
+
+func TestNewFieldMask(t *testing.T) {
+	got := NewFieldMask("User.Name", "User.Address.City", "ID", "Next.User", "Next.User.Age")
+	want := FieldMask{
+		"ID":   nil,
+		"User": {"Name": nil, "Address": {"City": nil}},
+		"Next": {"User": nil},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestEncodeMasked(t *testing.T) {
+	src := maskTyp{
+		ID: 1,
+		User: maskUser{
+			Name:    "bob",
+			Age:     30,
+			Address: &maskAddress{"Main St", "Springfield"},
+			Tags:    []string{"a"},
+		},
+		Next: &maskTyp{ID: 2, User: maskUser{Name: "alice", Age: 5}},
+	}
+	buf := EncodeMasked(&src, NewFieldMask("User.Name", "User.Address.City", "Next.User.Age"))
+	if len(buf) >= len(Marshal(&src)) {
+		t.Fatalf("masked encoding is not smaller than the full one")
+	}
+
+	dst := maskTyp{ID: 7, User: maskUser{Name: "x", Age: 1, Tags: []string{"b"}}}
+	if n := DecodeMasked(buf, &dst); n != len(buf) {
+		t.Fatalf("read %d of %d bytes", n, len(buf))
+	}
+	want := maskTyp{
+		ID: 7,
+		User: maskUser{
+			Name:    "bob",
+			Age:     1,
+			Address: &maskAddress{City: "Springfield"},
+			Tags:    []string{"b"},
+		},
+		Next: &maskTyp{User: maskUser{Age: 5}},
+	}
+	Assert(t, buf, want, dst)
+}
+
+func TestEncodeMaskedUnknownField(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("no panic for an unknown field")
+		}
+	}()
+	EncodeMasked(&maskTyp{}, NewFieldMask("User.Email"))
+}
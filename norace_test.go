@@ -0,0 +1,5 @@
+//go:build !race
+
+package gotiny
+
+const raceEnabled = false
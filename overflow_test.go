@@ -0,0 +1,233 @@
+package gotiny
+
+import (
+	"bytes"
+	"errors"
+	"hash"
+	"hash/fnv"
+	"math"
+	"reflect"
+	"runtime/debug"
+	"strconv"
+	"testing"
+)
+
+// The tests in this file pass on 32-bit and 64-bit platforms alike; run them with
+// GOARCH=386 go test to check the decoding of values encoded on a 64-bit platform.
+
+// TestCrossWidth checks that int, uint and uintptr are encoded like int64 and uint64
+// on every platform, so that values that fit decode into either.
+func TestCrossWidth(t *testing.T) {
+	ints := []int64{0, -1, 1, math.MinInt32, math.MaxInt32, -300, 1 << 20}
+	for _, v := range ints {
+		wide, native := Marshal(&v), Marshal(ptr(int(v)))
+		if !bytes.Equal(wide, native) {
+			t.Errorf("int %d: encoded as %x, int64 as %x", v, native, wide)
+		}
+		var got int
+		if _, err := UnmarshalChecked(wide, &got); err != nil || int64(got) != v {
+			t.Errorf("int64 %d decoded into int as %d, %v", v, got, err)
+		}
+	}
+	uints := []uint64{0, 1, 127, 128, math.MaxUint16, math.MaxUint32}
+	for _, v := range uints {
+		wide := Marshal(&v)
+		if native := Marshal(ptr(uint(v))); !bytes.Equal(wide, native) {
+			t.Errorf("uint %d: encoded as %x, uint64 as %x", v, native, wide)
+		}
+		if native := Marshal(ptr(uintptr(v))); !bytes.Equal(wide, native) {
+			t.Errorf("uintptr %d: encoded as %x, uint64 as %x", v, native, wide)
+		}
+		var u uint
+		var p uintptr
+		if _, err := UnmarshalChecked(append(wide, wide...), &u, &p); err != nil || uint64(u) != v || uint64(p) != v {
+			t.Errorf("uint64 %d decoded as uint %d and uintptr %d, %v", v, u, p, err)
+		}
+	}
+
+	type wideT struct {
+		I []int64
+		U [2]uint64
+		M map[int64]uint64
+	}
+	type nativeT struct {
+		I []int
+		U [2]uint
+		M map[int]uint
+	}
+	src := wideT{I: ints, U: [2]uint64{0, math.MaxUint32}, M: map[int64]uint64{math.MinInt32: math.MaxUint32}}
+	var dst nativeT
+	if _, err := UnmarshalChecked(Marshal(&src), &dst); err != nil {
+		t.Fatal(err)
+	}
+	back := wideT{U: [2]uint64{uint64(dst.U[0]), uint64(dst.U[1])}, M: map[int64]uint64{}}
+	for _, v := range dst.I {
+		back.I = append(back.I, int64(v))
+	}
+	for k, v := range dst.M {
+		back.M[int64(k)] = uint64(v)
+	}
+	if !reflect.DeepEqual(back, src) {
+		t.Errorf("decoded %v from %v", dst, src)
+	}
+}
+
+// TestOverflow decodes values that fit in int, uint and uintptr only where they have
+// 64 bits, so they overflow on 32-bit platforms.
+func TestOverflow(t *testing.T) {
+	narrow := strconv.IntSize == 32
+	type intsT struct {
+		S []int
+		M map[int]string
+	}
+	type wideIntsT struct {
+		S []int64
+		M map[int64]string
+	}
+	tests := []struct {
+		name     string
+		src, dst any // pointers to the encoded value and to the value decoded into
+		want     any // value decoded in the OverflowSaturate mode on a 32-bit platform
+	}{
+		{"int/max", ptr(int64(math.MaxInt32 + 1)), new(int), math.MaxInt},
+		{"int/min", ptr(int64(math.MinInt32 - 1)), new(int), math.MinInt},
+		{"int/int64-max", ptr(int64(math.MaxInt64)), new(int), math.MaxInt},
+		{"uint", ptr(uint64(math.MaxUint32 + 1)), new(uint), uint(math.MaxUint)},
+		{"uintptr", ptr(uint64(math.MaxUint64)), new(uintptr), ^uintptr(0)},
+		{"slice", &wideIntsT{S: []int64{1, 1 << 40}}, new(intsT), intsT{S: []int{1, math.MaxInt}}},
+		{"map", &wideIntsT{M: map[int64]string{-1 << 40: "x"}}, new(intsT), intsT{M: map[int]string{math.MinInt: "x"}}},
+	}
+	for _, tt := range tests {
+		buf := Marshal(tt.src)
+		n, err := UnmarshalChecked(buf, tt.dst)
+		if narrow {
+			if !errors.Is(err, ErrOverflow) {
+				t.Errorf("%s: got error %v, want ErrOverflow", tt.name, err)
+			}
+		} else if err != nil || n != len(buf) {
+			t.Errorf("%s: decoded %d of %d bytes, %v", tt.name, n, len(buf), err)
+		}
+
+		d := NewDecoderWithPtr(tt.dst)
+		d.SetOverflowMode(OverflowSaturate)
+		if n, err := d.DecodeChecked(buf, tt.dst); err != nil || n != len(buf) {
+			t.Errorf("%s: saturating decoded %d of %d bytes, %v", tt.name, n, len(buf), err)
+		}
+		if narrow {
+			if got := reflect.ValueOf(tt.dst).Elem().Interface(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: saturated to %v, want %v", tt.name, got, tt.want)
+			}
+		}
+	}
+
+	var r Raw[int]
+	r.buf = Marshal(ptr(int64(1 << 40)))
+	if _, err := r.Get(); narrow != errors.Is(err, ErrOverflow) {
+		t.Errorf("Raw.Get: got error %v", err)
+	}
+}
+
+func TestUnmarshalChecked(t *testing.T) {
+	src := []string{"a", "bc"}
+	buf := Marshal(&src)
+	var dst []string
+	// the spare capacity after the cut must not be read
+	if _, err := UnmarshalChecked(buf[:len(buf)-1], &dst); err == nil || errors.Is(err, ErrOverflow) {
+		t.Errorf("truncated input: got error %v", err)
+	}
+	// the pooled decoder must be usable again after the failure
+	if n, err := UnmarshalChecked(buf, &dst); err != nil || n != len(buf) || !reflect.DeepEqual(dst, src) {
+		t.Errorf("decoded %q from %d of %d bytes, %v", dst, n, len(buf), err)
+	}
+}
+
+// TestLength checks that lengths are written as they were when they were encoded as
+// uint32 varints, and that longer ones survive on platforms where they fit in an int.
+func TestLength(t *testing.T) {
+	for _, l := range []uint32{0, 127, 128, 1<<14 - 1, 1 << 14, 1<<28 - 1, 1 << 28, math.MaxInt32} {
+		var old, e Encoder
+		old.encUint32(l)
+		e.encLength(int(l))
+		if !bytes.Equal(e.buf, old.buf) {
+			t.Errorf("length %d: encoded as %x, was %x", l, e.buf, old.buf)
+		}
+		if got := lengthLen(int(l)); got != len(e.buf) {
+			t.Errorf("length %d: size %d, encoded in %d bytes", l, got, len(e.buf))
+		}
+	}
+
+	for _, l := range []uint64{math.MaxUint32 + 1, 1<<35 + 3, math.MaxInt64} {
+		var e Encoder
+		e.encUint64(l)
+		d := Decoder{buf: e.buf}
+		d.SetOverflowMode(OverflowSaturate) // lengths never saturate
+		got, err := func() (n int, err error) {
+			defer func() {
+				if x := recover(); x != nil {
+					err = decodeError(x)
+				}
+			}()
+			return d.decLength(), nil
+		}()
+		if strconv.IntSize == 32 {
+			if !errors.Is(err, ErrOverflow) {
+				t.Errorf("length %d: got error %v, want ErrOverflow", l, err)
+			}
+		} else if err != nil || uint64(got) != l {
+			t.Errorf("length %d: decoded %d, %v", l, got, err)
+		}
+	}
+}
+
+// hashRecorder is a hash.Hash that records the bytes written to it.
+type hashRecorder struct {
+	hash.Hash
+	head []byte // the first bytes written
+	last byte   // the last byte written
+	n    int    // the number of bytes written
+}
+
+func (h *hashRecorder) Write(b []byte) (int, error) {
+	if len(h.head) < 16 {
+		h.head = append(h.head, b[:min(len(b), 16-len(h.head))]...)
+	}
+	if len(b) > 0 {
+		h.last = b[len(b)-1]
+	}
+	h.n += len(b)
+	return len(b), nil
+}
+
+// TestHugeBytes encodes a byte slice longer than 4 GiB, whose length must not wrap.
+func TestHugeBytes(t *testing.T) {
+	if testing.Short() || strconv.IntSize == 32 {
+		t.Skip("needs 4 GiB of memory")
+	}
+	if raceEnabled {
+		t.Skip("the race detector checks every byte of the slice, which takes too long")
+	}
+	l := uint64(1)<<32 + 1 // not a constant, which would not compile where int has 32 bits
+	big, free := zeroPages(int(l))
+	if big == nil {
+		t.Skip("cannot map 4 GiB of zero pages")
+	}
+	defer free()
+	// only the buffer of Hash takes memory
+	debug.FreeOSMemory()
+	v := struct {
+		A bool
+		B []byte
+		C bool
+	}{true, big, true}
+	h := hashRecorder{Hash: fnv.New64a()}
+	if err := Hash(v, &h); err != nil {
+		t.Fatal(err)
+	}
+	if want := Size(&v); h.n != want {
+		t.Errorf("wrote %d bytes, Size is %d", h.n, want)
+	}
+	// A, the nil flag of B and C share a byte, followed by the length of B and its bytes
+	if want := []byte{7, 0x81, 0x80, 0x80, 0x80, 0x10, 0}; !bytes.HasPrefix(h.head, want) || h.last != 0 {
+		t.Errorf("encoding starts with %x and ends with %x, want %x and 00", h.head, h.last, want)
+	}
+}
@@ -0,0 +1,190 @@
+package gotiny
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// Plain-old-data (POD) types are structs and arrays made only of fixed-size numbers:
+// int8 to int64, uint8 to uint64, float32, float64, complex64, complex128,
+// and arrays and structs of them. int, uint and uintptr are excluded since their
+// size depends on the platform, and so is bool.
+//
+// A POD type opted in with RegisterPOD, or by a blank field tagged `gotiny:"pod"`,
+//
+//	type Vec3 struct {
+//		_       struct{} `gotiny:"pod"`
+//		X, Y, Z float32
+//	}
+//
+// is encoded as its numbers in field order, each in little-endian byte order and
+// without padding, instead of field by field as varints. The encoding has a fixed
+// length and is copied at memory speed on little-endian hosts.
+// A POD type is always encoded as a whole, so field paths cannot select fields inside it.
+
+var (
+	podTypes = map[reflect.Type]bool{}
+	podLock  sync.Mutex
+	podSnap  snapshot[reflect.Type, bool]
+
+	littleEndian = func() bool {
+		x := uint16(1)
+		return *(*byte)(unsafe.Pointer(&x)) == 1
+	}()
+)
+
+// RegisterPOD opts the type of v in to the plain-old-data encoding.
+// It panics if the type is not a POD type. It must be called before
+// values of the type, or of types containing it, are first encoded or decoded.
+func RegisterPOD(v any) {
+	registerPOD(reflect.TypeOf(v))
+}
+
+// registerPOD adds rt to the POD types, or panics if it is not one.
+func registerPOD(rt reflect.Type) {
+	if _, ok := podLayout(rt, 0, nil); !ok || (rt.Kind() != reflect.Struct && rt.Kind() != reflect.Array) {
+		panic("gotiny: " + rt.String() + " is not a plain-old-data type")
+	}
+	podLock.Lock()
+	defer podLock.Unlock()
+	podTypes[rt] = true
+	podSnap.store(podTypes)
+}
+
+// isPOD reports whether rt uses the plain-old-data encoding. A struct opted in by
+// its tag is checked and registered the first time, so that every engine family
+// panics alike if it is not a POD type.
+func isPOD(rt reflect.Type) bool {
+	if podSnap.load()[rt] {
+		return true
+	}
+	if rt.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < rt.NumField(); i++ {
+		if field := rt.Field(i); field.Name == "_" && strings.TrimSpace(field.Tag.Get("gotiny")) == "pod" {
+			registerPOD(rt)
+			return true
+		}
+	}
+	return false
+}
+
+// podSpan is a run of n bytes at offset off holding numbers of width bytes each.
+// On little-endian hosts the bytes are already in wire order, so width is 1
+// and adjacent spans are merged.
+type podSpan struct {
+	off, n uintptr
+	width  uintptr
+}
+
+// podLayout appends the spans of the numbers of a value of type rt, at offset off,
+// to spans. It returns false if rt is not a POD type.
+func podLayout(rt reflect.Type, off uintptr, spans []podSpan) ([]podSpan, bool) {
+	if enc, _ := implementOtherSerializer(rt); enc != nil {
+		return nil, false
+	}
+	switch rt.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return addPODSpan(spans, off, rt.Size(), rt.Size()), true
+	case reflect.Complex64, reflect.Complex128:
+		return addPODSpan(spans, off, rt.Size(), rt.Size()/2), true
+	case reflect.Array:
+		et := rt.Elem()
+		eSpans, ok := podLayout(et, 0, nil)
+		if !ok {
+			return nil, false
+		}
+		for i := 0; i < rt.Len(); i++ {
+			for _, s := range eSpans {
+				spans = addPODSpan(spans, off+uintptr(i)*et.Size()+s.off, s.n, s.width)
+			}
+		}
+		return spans, true
+	case reflect.Struct:
+		if rawElem(rt) != nil {
+			return nil, false
+		}
+		for i := 0; i < rt.NumField(); i++ {
+			field := rt.Field(i)
+			if ignoreField(field) || field.Type.Size() == 0 {
+				continue
+			}
+			var ok bool
+			if spans, ok = podLayout(field.Type, off+field.Offset, spans); !ok {
+				return nil, false
+			}
+		}
+		return spans, true
+	}
+	return nil, false
+}
+
+func addPODSpan(spans []podSpan, off, n, width uintptr) []podSpan {
+	if littleEndian {
+		width = 1
+	}
+	if l := len(spans) - 1; l >= 0 && spans[l].off+spans[l].n == off && spans[l].width == width {
+		spans[l].n += n
+		return spans
+	}
+	return append(spans, podSpan{off: off, n: n, width: width})
+}
+
+// podSize returns the length of the encoding of the POD type rt.
+func podSize(rt reflect.Type) int {
+	spans, _ := podLayout(rt, 0, nil)
+	size := 0
+	for _, s := range spans {
+		size += int(s.n)
+	}
+	return size
+}
+
+func buildPODEncEngine(rt reflect.Type) encEng {
+	spans, ok := podLayout(rt, 0, nil)
+	if !ok {
+		panic("gotiny: " + rt.String() + " is not a plain-old-data type")
+	}
+	return func(e *Encoder, p unsafe.Pointer) {
+		for _, s := range spans {
+			b := unsafe.Slice((*byte)(unsafe.Add(p, s.off)), s.n)
+			if s.width == 1 {
+				e.buf = append(e.buf, b...)
+				continue
+			}
+			for i := uintptr(0); i < s.n; i += s.width {
+				for j := s.width; j > 0; j-- {
+					e.buf = append(e.buf, b[i+j-1])
+				}
+			}
+		}
+	}
+}
+
+func buildPODDecEngine(rt reflect.Type) decEng {
+	spans, ok := podLayout(rt, 0, nil)
+	if !ok {
+		panic("gotiny: " + rt.String() + " is not a plain-old-data type")
+	}
+	return func(d *Decoder, p unsafe.Pointer) {
+		for _, s := range spans {
+			b := unsafe.Slice((*byte)(unsafe.Add(p, s.off)), s.n)
+			src := d.buf[d.index : d.index+int(s.n)]
+			d.index += int(s.n)
+			if s.width == 1 {
+				copy(b, src)
+				continue
+			}
+			for i := uintptr(0); i < s.n; i += s.width {
+				for j := uintptr(0); j < s.width; j++ {
+					b[i+j] = src[i+s.width-1-j]
+				}
+			}
+		}
+	}
+}
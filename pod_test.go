@@ -0,0 +1,138 @@
+package gotiny
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+)
+
+type podVec struct {
+	_    struct{} `gotiny:"pod"`
+	X, Y float32
+	C    complex64
+	Skip uint64 `gotiny:"-"`
+}
+
+type podSample struct {
+	T    int64
+	Pos  podVec
+	Mat  [2][2]float64
+	Flag int8
+	ID   uint16
+}
+
+type podMatrix [2][3]int32
+
+func init() {
+	RegisterPOD(podSample{})
+	RegisterPOD(podMatrix{})
+}
+
+func TestPOD(t *testing.T) {
+	v := podVec{X: 1, Y: -2, C: complex(3, 4)}
+	buf := Marshal(&v)
+	want := []byte{0, 0, 0x80, 0x3f, 0, 0, 0, 0xc0, 0, 0, 0x40, 0x40, 0, 0, 0x80, 0x40}
+	if !bytes.Equal(buf, want) {
+		t.Fatalf("encoded %x, want %x", buf, want)
+	}
+	var r podVec
+	if n := Unmarshal(buf, &r); n != len(buf) || r != v {
+		t.Fatalf("decoded %v (%d bytes), want %v", r, n, v)
+	}
+
+	s := podSample{T: -1, Pos: v, Mat: [2][2]float64{{1, math.Pi}, {math.Inf(-1), 0}}, Flag: -3, ID: 0xabcd}
+	buf = Marshal(&s)
+	if len(buf) != 8+16+32+1+2 || Size(&s) != len(buf) {
+		t.Fatalf("encoded %d bytes, Size %d", len(buf), Size(&s))
+	}
+	var rs podSample
+	Unmarshal(buf, &rs)
+	Assert(t, buf, s, rs)
+
+	m := podMatrix{{1, -2, 3}, {math.MaxInt32, math.MinInt32, 0}}
+	buf = Marshal(&m)
+	if !bytes.Equal(buf[4:8], []byte{0xfe, 0xff, 0xff, 0xff}) {
+		t.Fatalf("encoded %x", buf)
+	}
+	var rm podMatrix
+	Unmarshal(buf, &rm)
+	Assert(t, buf, m, rm)
+}
+
+func TestPODField(t *testing.T) {
+	type msg struct {
+		Name    string
+		Samples []podSample
+		Vec     *podVec
+		Ok      bool
+	}
+	v := msg{
+		Name:    "abc",
+		Samples: []podSample{{T: 1}, {ID: 2, Pos: podVec{X: 3}}},
+		Vec:     &podVec{Y: 4},
+		Ok:      true,
+	}
+	buf := Marshal(&v)
+	if Size(&v) != len(buf) {
+		t.Fatalf("Size %d, encoded %d bytes", Size(&v), len(buf))
+	}
+	var r msg
+	Unmarshal(buf, &r)
+	Assert(t, buf, v, r)
+
+	var d Decoder
+	if n := d.Skip(buf, reflect.TypeOf(v)); n != len(buf) {
+		t.Fatalf("skipped %d of %d bytes", n, len(buf))
+	}
+	var vec *podVec
+	if err := DecodePath(buf, reflect.TypeOf(v), "Vec", &vec); err != nil || *vec != *v.Vec {
+		t.Fatalf("DecodePath: %v, %v", vec, err)
+	}
+	var x float32
+	if err := DecodePath(buf, reflect.TypeOf(v), "Vec.X", &x); err == nil {
+		t.Fatal("DecodePath selected a field inside a POD type")
+	}
+}
+
+func TestRegisterPODInvalid(t *testing.T) {
+	for _, v := range []any{
+		struct{ A int }{},
+		struct{ A bool }{},
+		struct{ A *int32 }{},
+		[2]string{},
+		float64(0),
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterPOD(%T) did not panic", v)
+				}
+			}()
+			RegisterPOD(v)
+		}()
+	}
+}
+
+func TestPODTagInvalid(t *testing.T) {
+	type notPOD struct {
+		_ struct{} `gotiny:"pod"`
+		X int32
+		S string
+	}
+	v := notPOD{X: 1, S: "s"}
+	for name, f := range map[string]func(){
+		"Marshal": func() { Marshal(&v) },
+		"Size":    func() { Size(&v) },
+		"Decoder": func() { NewDecoderWithPtr(&v) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s of a struct tagged as POD with a string did not panic", name)
+				}
+			}()
+			f()
+		}()
+	}
+}
@@ -0,0 +1,77 @@
+package gotiny
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestMarshalAppend(t *testing.T) {
+	prefix := []byte("prefix")
+	buf := MarshalAppend(append([]byte(nil), prefix...), &vAstruct, &vstring)
+	if !bytes.HasPrefix(buf, prefix) || !bytes.Equal(buf[len(prefix):], Marshal(&vAstruct, &vstring)) {
+		t.Fatal("MarshalAppend differs from Marshal")
+	}
+	var a tA
+	var s string
+	Unmarshal(buf[len(prefix):], &a, &s)
+	Assert(t, buf, vAstruct, a)
+	Assert(t, buf, vstring, s)
+}
+
+func TestMarshalAppendAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector makes sync.Pool drop encoders")
+	}
+	buf := make([]byte, 0, 1024)
+	var a tA
+	allocs := testing.AllocsPerRun(100, func() {
+		buf = MarshalAppend(buf[:0], &vAstruct)
+		Unmarshal(buf, &a)
+	})
+	if allocs > 1 { // decoding a.Name allocates the string
+		t.Fatalf("%v allocations per run", allocs)
+	}
+}
+
+func TestPoolsParallel(t *testing.T) {
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var buf []byte
+			for i := 0; i < 200; i++ {
+				v, m := gentA(), map[string]int{randString(3): i}
+				buf = MarshalAppend(buf[:0], &v, &m)
+				var rv tA
+				var rm map[string]int
+				Unmarshal(buf, &rv, &rm)
+				Assert(t, buf, v, rv)
+				Assert(t, buf, m, rm)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestPoolNilArguments(t *testing.T) {
+	var s string
+	buf := Marshal(&s)
+	for name, f := range map[string]func(){
+		"Marshal(nil)":       func() { Marshal(nil) },
+		"Marshal(nil, &s)":   func() { Marshal(nil, &s) },
+		"Marshal(&s, nil)":   func() { Marshal(&s, nil) },
+		"Unmarshal(nil)":     func() { Unmarshal(buf, nil) },
+		"Unmarshal(&s, nil)": func() { Unmarshal(buf, &s, nil) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: no panic", name)
+				}
+			}()
+			f()
+		}()
+	}
+}
@@ -0,0 +1,7 @@
+//go:build race
+
+package gotiny
+
+// raceEnabled reports whether the tests run with the race detector, which makes
+// sync.Pool drop items at random, so allocation counts are not meaningful.
+const raceEnabled = true
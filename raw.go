@@ -0,0 +1,70 @@
+package gotiny
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+/*
+Raw holds the encoding of a value of type T without decoding it. Decoding a Raw
+only finds the end of the encoded value and keeps its bytes, and encoding a Raw
+writes those bytes back unchanged, so large nested values can be passed on
+without being materialized. Get decodes the value on demand.
+
+The bytes of a Raw are the encoding of the value on its own, as produced by
+Marshal, so bools inside it are not packed together with the bools around it.
+Like a decoded []byte, a decoded Raw refers to the buffer it was decoded from.
+The zero Raw holds the zero value of T.
+*/
+type Raw[T any] struct {
+	buf []byte
+}
+
+// rawValue is implemented by all Raw types. It lets the engine builders recognize them.
+type rawValue interface {
+	rawType() reflect.Type
+}
+
+var (
+	rawValueType = reflect.TypeFor[rawValue]()
+	rawPkgPath   = reflect.TypeFor[Raw[int]]().PkgPath()
+)
+
+func (Raw[T]) rawType() reflect.Type { return reflect.TypeFor[T]() }
+
+// Set replaces the contents of r with the encoding of v.
+func (r *Raw[T]) Set(v T) { r.buf = Marshal(&v) }
+
+// Bytes returns the encoding held by r, or nil for the zero Raw.
+func (r Raw[T]) Bytes() []byte { return r.buf }
+
+// Get decodes the value held by r.
+func (r Raw[T]) Get() (v T, err error) {
+	if r.buf == nil {
+		return
+	}
+	defer func() {
+		if x := recover(); x != nil {
+			if e, ok := x.(error); ok { // such as one wrapping ErrOverflow
+				err = fmt.Errorf("gotiny: decoding %s: %w", reflect.TypeFor[T](), e)
+			} else {
+				err = fmt.Errorf("gotiny: decoding %s: %v", reflect.TypeFor[T](), x)
+			}
+		}
+	}()
+	if n := Unmarshal(r.buf, &v); n != len(r.buf) {
+		err = fmt.Errorf("gotiny: decoding %s: %d trailing bytes", reflect.TypeFor[T](), len(r.buf)-n)
+	}
+	return
+}
+
+// rawElem returns the type T if rt is Raw[T], and nil otherwise. Structs that embed
+// a Raw have its methods too, so the name of rt is checked as well.
+func rawElem(rt reflect.Type) reflect.Type {
+	if rt == nil || rt.Kind() != reflect.Struct || rt.PkgPath() != rawPkgPath ||
+		!strings.HasPrefix(rt.Name(), "Raw[") || !rt.Implements(rawValueType) {
+		return nil
+	}
+	return reflect.Zero(rt).Interface().(rawValue).rawType()
+}
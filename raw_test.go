@@ -0,0 +1,118 @@
+package gotiny
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+type rawPayload struct {
+	Items []bTyp
+	On    bool
+	M     map[int]int
+}
+
+type rawMsg struct {
+	First   bool
+	Payload Raw[rawPayload]
+	Last    bool
+	Ptr     *Raw[[]bool]
+}
+
+func TestRaw(t *testing.T) {
+	payload := rawPayload{Items: gentBase(), On: true, M: vmap}
+	src := rawMsg{First: true, Last: true, Ptr: &Raw[[]bool]{}}
+	src.Payload.Set(payload)
+	src.Ptr.Set([]bool{true, false, true})
+
+	buf := Marshal(&src)
+	var dst rawMsg
+	if n := Unmarshal(buf, &dst); n != len(buf) {
+		t.Fatalf("read %d of %d bytes", n, len(buf))
+	}
+	if !dst.First || !dst.Last || !bytes.Equal(dst.Payload.Bytes(), src.Payload.Bytes()) {
+		t.Fatalf("got %+v", dst)
+	}
+	got, err := dst.Payload.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	Assert(t, buf, payload, got)
+	bools, err := dst.Ptr.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	Assert(t, buf, []bool{true, false, true}, bools)
+
+	if again := Marshal(&dst); !bytes.Equal(again, buf) {
+		t.Fatal("re-encoding a decoded Raw changed its bytes")
+	}
+	var d Decoder
+	if n := d.Skip(buf, reflect.TypeOf(src)); n != len(buf) {
+		t.Fatalf("skipped %d of %d bytes", n, len(buf))
+	}
+}
+
+func TestRawZero(t *testing.T) {
+	src := rawMsg{Last: true}
+	buf := Marshal(&src)
+	dst := rawMsg{First: true}
+	Unmarshal(buf, &dst)
+	if dst.First || !dst.Last {
+		t.Fatalf("got %+v", dst)
+	}
+	got, err := dst.Payload.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	Assert(t, buf, rawPayload{}, got)
+}
+
+func TestRawCorrupt(t *testing.T) {
+	var r Raw[string]
+	r.Set("hello")
+	r.buf = r.buf[:3]
+	if _, err := r.Get(); err == nil {
+		t.Fatal("no error for a truncated Raw")
+	}
+}
+
+// rawEmbed has the methods of Raw by promotion, but is not a Raw.
+type rawEmbed struct {
+	Raw[int]
+	X int
+	S string
+}
+
+func TestRawEmbedded(t *testing.T) {
+	src := rawEmbed{X: 42, S: "hello"}
+	src.Set(7)
+	buf := Marshal(&src)
+	var dst rawEmbed
+	if n := Unmarshal(buf, &dst); n != len(buf) || dst.X != 42 || dst.S != "hello" {
+		t.Fatalf("decoded %+v from %d of %d bytes", dst, n, len(buf))
+	}
+	if v, err := dst.Get(); err != nil || v != 7 {
+		t.Fatalf("Get: %d, %v", v, err)
+	}
+	rt := reflect.TypeFor[rawEmbed]()
+	if n := NewDecoderWithType(rt).Skip(buf, rt); n != len(buf) {
+		t.Errorf("skipped %d of %d bytes", n, len(buf))
+	}
+	if n := Size(&src); n != len(buf) {
+		t.Errorf("size %d, encoded in %d bytes", n, len(buf))
+	}
+	var clone rawEmbed
+	Clone(&clone, &src)
+	if clone.X != 42 || clone.S != "hello" || !bytes.Equal(clone.Bytes(), src.Bytes()) {
+		t.Errorf("cloned %+v", clone)
+	}
+	other := src
+	other.X++
+	if Equal(src, other) || len(Diff(src, other)) != 1 || Sum64(src) == Sum64(other) {
+		t.Error("a change of a field next to the Raw goes unnoticed")
+	}
+	if Fingerprint(new(rawEmbed)) == Fingerprint(new(Raw[int])) {
+		t.Error("the fingerprint ignores the fields next to the Raw")
+	}
+}
@@ -3,11 +3,18 @@ package gotiny
 import (
 	"reflect"
 	"strconv"
+	"sync"
 )
 
 var (
 	type2name = map[reflect.Type]string{}
 	name2type = map[string]reflect.Type{}
+
+	// registerLock guards type2name and name2type. Encoders and decoders
+	// read their snapshots instead, without locking.
+	registerLock  sync.Mutex
+	type2nameSnap snapshot[reflect.Type, string]
+	name2typeSnap snapshot[string, reflect.Type]
 )
 
 func GetName(obj any) string {
@@ -117,12 +124,20 @@ func getName(prefix []byte, rt reflect.Type) []byte {
 	return prefix
 }
 
+// getNameOfType returns the name rt is registered with, registering it under
+// its default name first if needed.
 func getNameOfType(rt reflect.Type) string {
-	if name, has := type2name[rt]; has {
+	if name, has := type2nameSnap.load()[rt]; has {
 		return name
-	} else {
-		return registerType(rt)
 	}
+	registerLock.Lock()
+	defer registerLock.Unlock()
+	if name, has := type2name[rt]; has {
+		return name // registered concurrently
+	}
+	name := GetNameByType(rt)
+	registerName(name, rt)
+	return name
 }
 
 func Register(i any) string {
@@ -149,6 +164,13 @@ func registerType(rt reflect.Type) string {
 //   - If the type is already registered with a different name.
 //   - If the name is already registered with a different type.
 func RegisterName(name string, rt reflect.Type) {
+	registerLock.Lock()
+	defer registerLock.Unlock()
+	registerName(name, rt)
+}
+
+// registerName registers rt with name. It must be called with registerLock held.
+func registerName(name string, rt reflect.Type) {
 	if name == "" {
 		panic("attempt to register empty name")
 	}
@@ -166,4 +188,6 @@ func RegisterName(name string, rt reflect.Type) {
 	}
 	name2type[name] = rt
 	type2name[rt] = name
+	name2typeSnap.store(name2type)
+	type2nameSnap.store(type2name)
 }
@@ -0,0 +1,23 @@
+//go:build gotiny_safe
+
+package gotiny
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// This file replaces unsafe.go in builds with the gotiny_safe tag. It uses only
+// the public API of reflect, so it does not depend on the layout of reflect.Value,
+// at the cost of copying values that are not addressable.
+
+// getUnsafePointer returns a pointer to the data held by rv. If rv is not
+// addressable, it points to a copy of the data, which must not be modified.
+func getUnsafePointer(rv reflect.Value) unsafe.Pointer {
+	if rv.CanAddr() {
+		return unsafe.Pointer(rv.UnsafeAddr())
+	}
+	nv := reflect.New(rv.Type())
+	nv.Elem().Set(rv)
+	return nv.UnsafePointer()
+}
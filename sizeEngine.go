@@ -0,0 +1,316 @@
+package gotiny
+
+import (
+	"math"
+	"math/bits"
+	"reflect"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// sizer accumulates the length of an encoding without writing it.
+// Bools are counted apart from the other bytes because eight of them
+// share a single byte of the output.
+type sizer struct {
+	n     int // number of bytes, excluding the bytes that hold bools
+	bools int // number of encoded bools
+}
+
+func (s *sizer) size() int { return s.n + (s.bools+7)/8 }
+
+type sizeEng func(*sizer, unsafe.Pointer) // 长度计算器
+
+var (
+	// rt2sizeEng is a map that associates Go types with the functions that compute
+	// the length of their encoding. It mirrors rt2encEng.
+	rt2sizeEng = map[reflect.Type]sizeEng{
+		reflect.TypeFor[[]byte]():    sizeBytes,
+		reflect.TypeFor[time.Time](): sizeTime,
+		reflect.TypeFor[struct{}]():  sizeIgnore,
+		reflect.TypeOf(nil):          sizeIgnore,
+	}
+
+	// sizeEngines is an array of sizeEng functions indexed by reflect.Kind.
+	sizeEngines = [...]sizeEng{
+		reflect.Bool:       sizeBool,
+		reflect.Int:        sizeInt,
+		reflect.Int8:       sizeByte,
+		reflect.Int16:      sizeInt16,
+		reflect.Int32:      sizeInt32,
+		reflect.Int64:      sizeInt64,
+		reflect.Uint:       sizeUint,
+		reflect.Uint8:      sizeByte,
+		reflect.Uint16:     sizeUint16,
+		reflect.Uint32:     sizeUint32,
+		reflect.Uint64:     sizeUint64,
+		reflect.Uintptr:    sizeUintptr,
+		reflect.Float32:    sizeFloat32,
+		reflect.Float64:    sizeFloat64,
+		reflect.Complex64:  sizeComplex64,
+		reflect.Complex128: sizeComplex128,
+		reflect.String:     sizeString,
+	}
+
+	sizeLock sync.Mutex
+	sizeSnap snapshot[reflect.Type, sizeEng]
+)
+
+// varintLen returns the number of bytes of the varint encoding of a value
+// whose highest set bit is at position l. max is the length of the longest
+// encoding of the type, which is 9 for uint64 since its last byte holds 8 bits.
+func varintLen(l, max int) int {
+	n := (l + 6) / 7
+	if n == 0 {
+		return 1
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
+
+func uint16Len(v uint16) int { return varintLen(bits.Len16(v), 3) }
+func uint32Len(v uint32) int { return varintLen(bits.Len32(v), 5) }
+func uint64Len(v uint64) int { return varintLen(bits.Len64(v), 9) }
+func lengthLen(l int) int    { return uint64Len(uint64(l)) }
+
+func sizeIgnore(*sizer, unsafe.Pointer)        {}
+func sizeBool(s *sizer, _ unsafe.Pointer)      { s.bools++ }
+func sizeByte(s *sizer, _ unsafe.Pointer)      { s.n++ }
+func sizeInt(s *sizer, p unsafe.Pointer)       { s.n += uint64Len(int64ToUint64(int64(*(*int)(p)))) }
+func sizeInt16(s *sizer, p unsafe.Pointer)     { s.n += uint16Len(int16ToUint16(*(*int16)(p))) }
+func sizeInt32(s *sizer, p unsafe.Pointer)     { s.n += uint32Len(int32ToUint32(*(*int32)(p))) }
+func sizeInt64(s *sizer, p unsafe.Pointer)     { s.n += uint64Len(int64ToUint64(*(*int64)(p))) }
+func sizeUint(s *sizer, p unsafe.Pointer)      { s.n += uint64Len(uint64(*(*uint)(p))) }
+func sizeUint16(s *sizer, p unsafe.Pointer)    { s.n += uint16Len(*(*uint16)(p)) }
+func sizeUint32(s *sizer, p unsafe.Pointer)    { s.n += uint32Len(*(*uint32)(p)) }
+func sizeUint64(s *sizer, p unsafe.Pointer)    { s.n += uint64Len(*(*uint64)(p)) }
+func sizeUintptr(s *sizer, p unsafe.Pointer)   { s.n += uint64Len(uint64(*(*uintptr)(p))) }
+func sizeFloat32(s *sizer, p unsafe.Pointer)   { s.n += uint32Len(float32ToUint32(*(*float32)(p))) }
+func sizeFloat64(s *sizer, p unsafe.Pointer)   { s.n += uint64Len(float64ToUint64(*(*float64)(p))) }
+func sizeComplex64(s *sizer, p unsafe.Pointer) { s.n += uint64Len(complex64ToUint64(*(*complex64)(p))) }
+func sizeComplex128(s *sizer, p unsafe.Pointer) {
+	c := *(*complex128)(p)
+	s.n += uint64Len(math.Float64bits(real(c))) + uint64Len(math.Float64bits(imag(c)))
+}
+func sizeString(s *sizer, p unsafe.Pointer) {
+	l := len(*(*string)(p))
+	s.n += lengthLen(l) + l
+}
+func sizeTime(s *sizer, p unsafe.Pointer) { s.n += uint64Len(uint64((*time.Time)(p).UnixNano())) }
+func sizeBytes(s *sizer, p unsafe.Pointer) {
+	s.bools++
+	if !isNil(p) {
+		l := len(*(*[]byte)(p))
+		s.n += lengthLen(l) + l
+	}
+}
+
+// fixedSize reports whether every value of rt has an encoding of the same length,
+// and if so returns that length as a number of bytes and a number of bools.
+func fixedSize(rt reflect.Type) (n, bools int, ok bool) {
+	if rt == reflect.TypeFor[struct{}]() {
+		return 0, 0, true
+	}
+	if enc, _ := implementOtherSerializer(rt); enc != nil || rawElem(rt) != nil {
+		return 0, 0, false
+	}
+	if isPOD(rt) {
+		return podSize(rt), 0, true
+	}
+	switch rt.Kind() {
+	case reflect.Bool:
+		return 0, 1, true
+	case reflect.Int8, reflect.Uint8:
+		return 1, 0, true
+	case reflect.Array:
+		if n, bools, ok = fixedSize(rt.Elem()); ok {
+			return n * rt.Len(), bools * rt.Len(), true
+		}
+	case reflect.Struct:
+		fields, _ := getFieldType(rt, 0)
+		for _, ft := range fields {
+			fn, fb, fok := fixedSize(ft)
+			if !fok {
+				return 0, 0, false
+			}
+			n, bools = n+fn, bools+fb
+		}
+		return n, bools, true
+	}
+	return 0, 0, false
+}
+
+// getSizeEngine retrieves or builds a size engine for the given reflect.Type,
+// in the same way getEncEngine does for encoding engines.
+func getSizeEngine(rt reflect.Type) sizeEng {
+	if engine := sizeSnap.load()[rt]; engine != nil {
+		return engine
+	}
+	sizeLock.Lock()
+	defer sizeLock.Unlock()
+	var engine sizeEng
+	buildSizeEngine(rt, &engine)
+	sizeSnap.store(rt2sizeEng)
+	return engine
+}
+
+// buildSizeEngine constructs a size engine for the given reflect.Type and assigns it to engPtr.
+// It follows the structure of buildEncEngine. Types whose encoding always has the same length
+// get an engine that adds a precomputed constant. Types implementing Serializer, BinaryMarshaler
+// or GobEncoder are encoded into a scratch buffer, as their length is only known to themselves.
+func buildSizeEngine(rt reflect.Type, engPtr *sizeEng) {
+	engine := rt2sizeEng[rt]
+	if engine != nil {
+		*engPtr = engine
+		return
+	}
+
+	if enc, _ := implementOtherSerializer(rt); enc != nil {
+		engine = func(s *sizer, p unsafe.Pointer) {
+			e := Encoder{}
+			enc(&e, p)
+			s.n += len(e.buf)
+		}
+		rt2sizeEng[rt] = engine
+		*engPtr = engine
+		return
+	}
+
+	if n, bools, ok := fixedSize(rt); ok {
+		engine = func(s *sizer, _ unsafe.Pointer) {
+			s.n += n
+			s.bools += bools
+		}
+		rt2sizeEng[rt] = engine
+		*engPtr = engine
+		return
+	}
+
+	kind := rt.Kind()
+	var eEng sizeEng
+	if et := rawElem(rt); et != nil {
+		defer buildSizeEngine(et, &eEng)
+		zero := reflect.New(et).UnsafePointer()
+		engine = func(s *sizer, p unsafe.Pointer) {
+			raw := *(*[]byte)(p)
+			if raw == nil {
+				// the encoding of a Raw stands on its own, bools included
+				rs := sizer{}
+				eEng(&rs, zero)
+				s.n += rs.size()
+				return
+			}
+			s.n += len(raw)
+		}
+		rt2sizeEng[rt] = engine
+		*engPtr = engine
+		return
+	}
+	switch kind {
+	case reflect.Ptr:
+		defer buildSizeEngine(rt.Elem(), &eEng)
+		engine = func(s *sizer, p unsafe.Pointer) {
+			s.bools++
+			if !isNil(p) {
+				eEng(s, *(*unsafe.Pointer)(p))
+			}
+		}
+	case reflect.Array:
+		et, l := rt.Elem(), rt.Len()
+		size := et.Size()
+		if b := getBulkEngine(et); b != nil {
+			engine = func(s *sizer, p unsafe.Pointer) { s.n += b.size(p, l) }
+			break
+		}
+		defer buildSizeEngine(et, &eEng)
+		engine = func(s *sizer, p unsafe.Pointer) {
+			for i := 0; i < l; i++ {
+				eEng(s, unsafe.Add(p, i*int(size)))
+			}
+		}
+	case reflect.Slice:
+		et := rt.Elem()
+		size := et.Size()
+		if n, bools, ok := fixedSize(et); ok {
+			engine = func(s *sizer, p unsafe.Pointer) {
+				s.bools++
+				if !isNil(p) {
+					l := (*sliceHeader)(p).len
+					s.n += lengthLen(l) + l*n
+					s.bools += l * bools
+				}
+			}
+			break
+		}
+		if b := getBulkEngine(et); b != nil {
+			engine = bulkSliceSizeEngine(b)
+			break
+		}
+		defer buildSizeEngine(et, &eEng)
+		engine = func(s *sizer, p unsafe.Pointer) {
+			s.bools++
+			if !isNil(p) {
+				header := (*sliceHeader)(p)
+				l := header.len
+				s.n += lengthLen(l)
+				for i := 0; i < l; i++ {
+					eEng(s, unsafe.Add(header.data, i*int(size)))
+				}
+			}
+		}
+	case reflect.Map:
+		var kEng sizeEng
+		kt, et := rt.Key(), rt.Elem()
+		defer buildSizeEngine(kt, &kEng)
+		defer buildSizeEngine(et, &eEng)
+		engine = func(s *sizer, p unsafe.Pointer) {
+			s.bools++
+			if !isNil(p) {
+				v := reflect.NewAt(rt, p).Elem()
+				s.n += lengthLen(v.Len())
+				key, val := reflect.New(kt).Elem(), reflect.New(et).Elem()
+				var iter reflect.MapIter
+				iter.Reset(v)
+				for iter.Next() {
+					key.SetIterKey(&iter)
+					val.SetIterValue(&iter)
+					kEng(s, unsafe.Pointer(key.UnsafeAddr()))
+					eEng(s, unsafe.Pointer(val.UnsafeAddr()))
+				}
+			}
+		}
+	case reflect.Struct:
+		fields, offs := getFieldType(rt, 0)
+		nf := len(fields)
+		fEngines := make([]sizeEng, nf)
+		defer func() {
+			for i := 0; i < nf; i++ {
+				buildSizeEngine(fields[i], &fEngines[i])
+			}
+		}()
+		engine = func(s *sizer, p unsafe.Pointer) {
+			for i, fEng := range fEngines {
+				fEng(s, unsafe.Add(p, offs[i]))
+			}
+		}
+	case reflect.Interface:
+		engine = func(s *sizer, p unsafe.Pointer) {
+			s.bools++
+			if !isNil(p) {
+				v := reflect.NewAt(rt, p).Elem().Elem()
+				et := v.Type()
+				l := len(getIfaceEnc(et).name)
+				s.n += lengthLen(l) + l
+				getSizeEngine(et)(s, getUnsafePointer(v))
+			}
+		}
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer, reflect.Invalid:
+		panic("not support " + rt.String() + " type")
+	default:
+		engine = sizeEngines[kind]
+	}
+	rt2sizeEng[rt] = engine
+	*engPtr = engine
+}
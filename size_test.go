@@ -0,0 +1,64 @@
+package gotiny
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestSize(t *testing.T) {
+	if n, l := Size(srci...), len(Marshal(srci...)); n != l {
+		t.Fatalf("Size: %d, encoded length: %d", n, l)
+	}
+	for i, p := range srci {
+		if n, l := Size(p), len(Marshal(p)); n != l {
+			t.Fatalf("%T (%d): Size: %d, encoded length: %d", p, i, n, l)
+		}
+	}
+}
+
+func TestSizeVarintWidths(t *testing.T) {
+	for _, v := range []uint64{0, 1<<7 - 1, 1 << 7, 1<<14 - 1, 1 << 14, 1<<28 - 1, 1 << 28, 1<<56 - 1, 1 << 56, math.MaxUint64} {
+		u16, u32, i := uint16(v), uint32(v), int64(v)
+		f32, f64 := float32(v), float64(v)
+		if n, l := Size(&v, &u16, &u32, &i, &f32, &f64), len(Marshal(&v, &u16, &u32, &i, &f32, &f64)); n != l {
+			t.Fatalf("%d: Size: %d, encoded length: %d", v, n, l)
+		}
+	}
+}
+
+func TestSizeFixed(t *testing.T) {
+	type fixed struct {
+		A [3]bool
+		B int8
+		C struct {
+			D [4]uint8
+			E bool
+		}
+		F struct{}
+	}
+	v := []fixed{{}, {}, {}}
+	if n, l := Size(&v), len(Marshal(&v)); n != l {
+		t.Fatalf("Size: %d, encoded length: %d", n, l)
+	}
+	if n, bools, ok := fixedSize(reflect.TypeOf(fixed{})); !ok || n != 5 || bools != 4 {
+		t.Fatalf("fixedSize: %d, %d, %v", n, bools, ok)
+	}
+}
+
+func TestSizeRaw(t *testing.T) {
+	type msg struct {
+		Ok   bool
+		Body Raw[tA]
+		Tail []bool
+	}
+	var v msg
+	if n, l := Size(&v), len(Marshal(&v)); n != l {
+		t.Fatalf("zero Raw: Size: %d, encoded length: %d", n, l)
+	}
+	v.Body.Set(vAstruct)
+	v.Tail = []bool{true, false, true}
+	if n, l := Size(&v), len(Marshal(&v)); n != l {
+		t.Fatalf("Size: %d, encoded length: %d", n, l)
+	}
+}
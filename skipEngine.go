@@ -0,0 +1,206 @@
+package gotiny
+
+import (
+	"reflect"
+	"sync"
+	"time"
+)
+
+type skipEng func(*Decoder) // 跳过器
+
+var (
+	// rt2skipEng is a map that associates Go types with the functions that skip over their encoding.
+	// It mirrors rt2decEng: every decoding engine has a skip engine that advances the decoder
+	// past the same bytes without storing or allocating anything.
+	rt2skipEng = map[reflect.Type]skipEng{
+		reflect.TypeFor[[]byte]():    skipBytes,
+		reflect.TypeFor[time.Time](): skipUint64,
+		reflect.TypeFor[struct{}]():  skipIgnore,
+		reflect.TypeOf(nil):          skipIgnore,
+	}
+
+	// skipEngines is an array of skipEng functions indexed by reflect.Kind.
+	skipEngines = [...]skipEng{
+		reflect.Bool:       skipBool,
+		reflect.Int:        skipUint64,
+		reflect.Int8:       skipByte,
+		reflect.Int16:      skipUint16,
+		reflect.Int32:      skipUint32,
+		reflect.Int64:      skipUint64,
+		reflect.Uint:       skipUint64,
+		reflect.Uint8:      skipByte,
+		reflect.Uint16:     skipUint16,
+		reflect.Uint32:     skipUint32,
+		reflect.Uint64:     skipUint64,
+		reflect.Uintptr:    skipUint64,
+		reflect.Float32:    skipUint32,
+		reflect.Float64:    skipUint64,
+		reflect.Complex64:  skipUint64,
+		reflect.Complex128: skipComplex128,
+		reflect.String:     skipString,
+	}
+
+	skipLock sync.Mutex
+	skipSnap snapshot[reflect.Type, skipEng]
+)
+
+func skipIgnore(*Decoder)           {}
+func skipBool(d *Decoder)           { d.decBool() }
+func skipByte(d *Decoder)           { d.index++ }
+func skipUint16(d *Decoder)         { d.decUint16() }
+func skipUint32(d *Decoder)         { d.decUint32() }
+func skipUint64(d *Decoder)         { d.decUint64() }
+func skipComplex128(d *Decoder)     { d.decUint64(); d.decUint64() }
+func skipString(d *Decoder)         { d.index += d.decLength() }
+func skipLengthPrefixed(d *Decoder) { d.index += d.decLength() }
+func skipBytes(d *Decoder) {
+	if d.decIsNotNil() {
+		d.index += d.decLength()
+	}
+}
+
+// getSkipEngine retrieves or builds a skip engine for the given reflect.Type,
+// in the same way getDecEngine does for decoding engines.
+func getSkipEngine(rt reflect.Type) skipEng {
+	if engine := skipSnap.load()[rt]; engine != nil {
+		return engine
+	}
+	skipLock.Lock()
+	defer skipLock.Unlock()
+	var engine skipEng
+	buildSkipEngine(rt, &engine)
+	skipSnap.store(rt2skipEng)
+	return engine
+}
+
+// buildSkipEngine constructs a skip engine for the given reflect.Type and assigns it to engPtr.
+// It follows the structure of buildDecEngine. Types implementing Serializer do not record the
+// length of their encoding, so they are decoded into a scratch value to find their end.
+func buildSkipEngine(rt reflect.Type, engPtr *skipEng) {
+	engine := rt2skipEng[rt]
+	if engine != nil {
+		*engPtr = engine
+		return
+	}
+
+	if _, dec := implementOtherSerializer(rt); dec != nil {
+		if _, ok := reflect.New(rt).Interface().(Serializer); ok {
+			engine = func(d *Decoder) { dec(d, reflect.New(rt).UnsafePointer()) }
+		} else {
+			engine = skipLengthPrefixed
+		}
+		rt2skipEng[rt] = engine
+		*engPtr = engine
+		return
+	}
+
+	if isPOD(rt) {
+		size := podSize(rt)
+		engine = func(d *Decoder) { d.index += size }
+		rt2skipEng[rt] = engine
+		*engPtr = engine
+		return
+	}
+
+	kind := rt.Kind()
+	var eEng skipEng
+	if et := rawElem(rt); et != nil {
+		defer buildSkipEngine(et, &eEng)
+		engine = func(d *Decoder) {
+			rd := Decoder{buf: d.buf, index: d.index} // as in the decoding engine of Raw
+			eEng(&rd)
+			d.index = rd.index
+		}
+		rt2skipEng[rt] = engine
+		*engPtr = engine
+		return
+	}
+	switch kind {
+	case reflect.Ptr:
+		defer buildSkipEngine(rt.Elem(), &eEng)
+		engine = func(d *Decoder) {
+			if d.decIsNotNil() {
+				eEng(d)
+			}
+		}
+	case reflect.Array:
+		et, l := rt.Elem(), rt.Len()
+		if isPlainKind(et) && et.Size() == 1 && et.Kind() != reflect.Bool {
+			engine = func(d *Decoder) { d.index += l }
+			break
+		}
+		if b := getBulkEngine(et); b != nil {
+			engine = func(d *Decoder) { b.skip(d, l) }
+			break
+		}
+		defer buildSkipEngine(et, &eEng)
+		engine = func(d *Decoder) {
+			for i := 0; i < l; i++ {
+				eEng(d)
+			}
+		}
+	case reflect.Slice:
+		et := rt.Elem()
+		if isPlainKind(et) && et.Size() == 1 && et.Kind() != reflect.Bool {
+			engine = skipBytes
+			break
+		}
+		if b := getBulkEngine(et); b != nil {
+			engine = bulkSliceSkipEngine(b)
+			break
+		}
+		defer buildSkipEngine(et, &eEng)
+		engine = func(d *Decoder) {
+			if d.decIsNotNil() {
+				for i, l := 0, d.decLength(); i < l; i++ {
+					eEng(d)
+				}
+			}
+		}
+	case reflect.Map:
+		var kEng skipEng
+		defer buildSkipEngine(rt.Key(), &kEng)
+		defer buildSkipEngine(rt.Elem(), &eEng)
+		engine = func(d *Decoder) {
+			if d.decIsNotNil() {
+				for i, l := 0, d.decLength(); i < l; i++ {
+					kEng(d)
+					eEng(d)
+				}
+			}
+		}
+	case reflect.Struct:
+		fields, _ := getFieldType(rt, 0)
+		nf := len(fields)
+		fEngines := make([]skipEng, nf)
+		defer func() {
+			for i := 0; i < nf; i++ {
+				buildSkipEngine(fields[i], &fEngines[i])
+			}
+		}()
+		engine = func(d *Decoder) {
+			for _, fEng := range fEngines {
+				fEng(d)
+			}
+		}
+	case reflect.Interface:
+		engine = func(d *Decoder) {
+			if d.decIsNotNil() {
+				l := d.decLength()
+				name := d.buf[d.index : d.index+l]
+				d.index += l
+				et, has := name2typeSnap.load()[string(name)]
+				if !has {
+					panic("unknown typ:" + string(name))
+				}
+				getSkipEngine(et)(d)
+			}
+		}
+	case reflect.Chan, reflect.Func, reflect.Invalid, reflect.UnsafePointer:
+		panic("not support " + rt.String() + " type")
+	default:
+		engine = skipEngines[kind]
+	}
+	rt2skipEng[rt] = engine
+	*engPtr = engine
+}
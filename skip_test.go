@@ -0,0 +1,123 @@
+package gotiny
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"unsafe"
+)
+
+func TestSkipEngines(t *testing.T) {
+	for i, src := range srci {
+		before, after := true, false
+		buf := Marshal(&before, src, &after)
+		d := Decoder{buf: buf}
+		decBool(&d, unsafe.Pointer(&before))
+		getSkipEngine(typs[i])(&d)
+		decBool(&d, unsafe.Pointer(&after))
+		if d.index != len(buf) || !before || after {
+			t.Errorf("skipping %T: read %d of %d bytes", src, d.index, len(buf))
+		}
+	}
+}
+
+type pathHeader struct {
+	Flags    []bool
+	TenantID string
+	Trace    *tA
+}
+
+type pathMsg struct {
+	Body   map[string][]byte
+	Header pathHeader
+	Meta   *pathHeader
+	Ok     bool
+}
+
+func TestDecodePath(t *testing.T) {
+	msg := pathMsg{
+		Body:   map[string][]byte{"a": []byte("payload")},
+		Header: pathHeader{Flags: []bool{true, false}, TenantID: "tenant", Trace: &tA{Name: "trace"}},
+		Ok:     true,
+	}
+	buf := Marshal(&msg)
+	rt := reflect.TypeOf(msg)
+
+	var id string
+	if err := DecodePath(buf, rt, "Header.TenantID", &id); err != nil || id != "tenant" {
+		t.Fatalf("got %q, %v", id, err)
+	}
+	var name string
+	if err := DecodePath(buf, rt, "Header.Trace.Name", &name); err != nil || name != "trace" {
+		t.Fatalf("got %q, %v", name, err)
+	}
+	var header pathHeader
+	if err := DecodePath(buf, rt, "Header", &header); err != nil {
+		t.Fatal(err)
+	}
+	Assert(t, buf, msg.Header, header)
+	var ok bool
+	if err := DecodePath(buf, rt, "Ok", &ok); err != nil || !ok {
+		t.Fatalf("got %v, %v", ok, err)
+	}
+	if err := DecodePath(buf, rt, "Meta.TenantID", &id); !errors.Is(err, ErrNilPath) {
+		t.Fatalf("got %v for a nil pointer", err)
+	}
+	if err := DecodePath(buf, rt, "Header.Missing", &id); err == nil {
+		t.Fatal("no error for a missing field")
+	}
+	if err := DecodePath(buf, rt, "Ok", &id); err == nil {
+		t.Fatal("no error for a type mismatch")
+	}
+	for i := range buf {
+		id = ""
+		if err := DecodePath(buf[:i], rt, "Header.TenantID", &id); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("cut to %d bytes: got error %v, want io.ErrUnexpectedEOF", i, err)
+		} else if err == nil && id != "tenant" {
+			t.Fatalf("cut to %d bytes: got %q", i, id)
+		}
+	}
+	if err := DecodePath(buf[:3], rt, "Header.TenantID", &id); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("cut to 3 bytes: got error %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestDecoderSkip(t *testing.T) {
+	type event struct {
+		Kind    int
+		Payload []string
+		Done    bool
+	}
+	var stream []byte
+	for i := 0; i < 10; i++ {
+		kind := i % 3
+		stream = append(stream, Marshal(&kind)...)
+		if kind == 0 {
+			stream = append(stream, Marshal(&event{Kind: i, Payload: []string{"a", "b"}, Done: true})...)
+		} else {
+			stream = append(stream, Marshal(&vbigStruct)...)
+		}
+	}
+
+	var d Decoder
+	var got []int
+	for len(stream) > 0 {
+		var kind int
+		stream = stream[Unmarshal(stream, &kind):]
+		if kind == 0 {
+			var ev event
+			stream = stream[Unmarshal(stream, &ev):]
+			got = append(got, ev.Kind)
+		} else {
+			stream = stream[d.Skip(stream, reflect.TypeOf(vbigStruct)):]
+		}
+	}
+	Assert(t, nil, []int{0, 3, 6, 9}, got)
+}
+
+var vbigStruct = struct {
+	A map[string]*tA
+	B [3][]bTyp
+	C any
+}{map[string]*tA{"a": {Name: "a"}}, varr, vInterface}
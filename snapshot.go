@@ -0,0 +1,30 @@
+package gotiny
+
+import (
+	"maps"
+	"sync/atomic"
+)
+
+// snapshot holds a read-only copy of a map, so that the engine caches and the
+// type registry can be read without locking. Writers change the map they own
+// while holding its lock and then store a new snapshot of it. Storing copies the
+// whole map, which suits caches that stop changing once every type in use has
+// been seen.
+type snapshot[K comparable, V any] struct {
+	p atomic.Pointer[map[K]V]
+}
+
+// load returns the latest snapshot. It must not be modified. Before the first
+// store it returns a nil map, on which every lookup misses.
+func (s *snapshot[K, V]) load() map[K]V {
+	if m := s.p.Load(); m != nil {
+		return *m
+	}
+	return nil
+}
+
+// store publishes a copy of m. Calls must be serialized by the lock guarding m.
+func (s *snapshot[K, V]) store(m map[K]V) {
+	m = maps.Clone(m)
+	s.p.Store(&m)
+}
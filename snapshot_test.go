@@ -0,0 +1,60 @@
+package gotiny
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type (
+	concA struct {
+		X int
+		P *concA
+	}
+	concB struct {
+		Y []string
+		M map[string]any
+	}
+	concPOD struct{ X, Y int16 }
+)
+
+// TestConcurrentBuild builds engines, registers the names of interface payloads
+// and registers POD types from many goroutines at once. Run it with -race.
+func TestConcurrentBuild(t *testing.T) {
+	vals := []any{
+		concA{1, &concA{X: 2}},
+		concB{[]string{"x"}, map[string]any{"a": concA{X: 3}}},
+		[]concA{{X: 4}},
+		map[int]concB{5: {M: map[string]any{"b": []any{concB{}}}}},
+		[2]any{concA{X: 6}, &concB{}},
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		RegisterPOD(concPOD{})
+		RegisterPOD([2]concPOD{})
+		RegisterPOD([3]concPOD{})
+	}()
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, v := range vals {
+				buf := Marshal(&v)
+				var r any
+				if Unmarshal(buf, &r); !reflect.DeepEqual(r, v) {
+					t.Errorf("decoded %#v, want %#v", r, v)
+				}
+				if Size(&v) != len(buf) {
+					t.Errorf("%T: Size %d, encoded %d bytes", v, Size(&v), len(buf))
+				}
+				var d Decoder
+				if n := d.Skip(buf, efaceType); n != len(buf) {
+					t.Errorf("%T: skipped %d of %d bytes", v, n, len(buf))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,119 @@
+package gotiny
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// EnvelopeWriter writes values to an io.Writer as a stream of envelopes, one for
+// each call to Encode, with the options it was created with.
+type EnvelopeWriter struct {
+	w       io.Writer
+	opts    EnvelopeOptions
+	payload []byte // the encoding of the values, reused across calls
+	buf     []byte // the envelope, reused across calls
+}
+
+// NewEnvelopeWriter returns an EnvelopeWriter that writes envelopes with the fields
+// and the compression selected by opts to w.
+func NewEnvelopeWriter(w io.Writer, opts EnvelopeOptions) *EnvelopeWriter {
+	return &EnvelopeWriter{w: w, opts: opts}
+}
+
+// Encode writes an envelope of the values pointed to by ps to the underlying writer
+// with a single Write call, and returns the error of that call.
+func (w *EnvelopeWriter) Encode(ps ...any) error {
+	w.payload = marshalAppend(w.payload[:0], w.opts.PackedFloats, ps)
+	w.buf = appendEnvelope(w.buf[:0], w.opts, w.payload, ps)
+	_, err := w.w.Write(w.buf)
+	return err
+}
+
+// EnvelopeReader reads values from a stream of envelopes, such as the one an
+// EnvelopeWriter writes. It buffers its input, so it may read past the last
+// envelope it decodes.
+type EnvelopeReader struct {
+	r    *bufio.Reader
+	opts DecodeOptions
+}
+
+// NewEnvelopeReader returns an EnvelopeReader that reads envelopes from r.
+func NewEnvelopeReader(r io.Reader) *EnvelopeReader {
+	return &EnvelopeReader{r: bufio.NewReader(r)}
+}
+
+// SetDecodeOptions sets the restrictions on the envelopes Decode accepts.
+func (r *EnvelopeReader) SetDecodeOptions(opts DecodeOptions) { r.opts = opts }
+
+// Decode reads the next envelope and decodes it into the values pointed to by is.
+// It returns io.EOF if the stream ends before the envelope starts, and the errors
+// UnmarshalEnvelope returns otherwise, or those of the underlying reader.
+func (r *EnvelopeReader) Decode(is ...any) error {
+	buf, err := r.peekHeader()
+	if len(buf) == 0 {
+		return err
+	}
+	h, herr := ReadHeader(buf)
+	if herr != nil {
+		if errors.Is(herr, io.ErrUnexpectedEOF) && err != nil && err != io.EOF {
+			return err
+		}
+		return herr
+	}
+	if err := r.opts.check(h); err != nil {
+		return err
+	}
+	var sum uint32
+	if h.HasChecksum {
+		sum = crc32.Checksum(buf[:h.Len], castagnoli)
+	}
+	r.r.Discard(h.Len)
+	// the payload is not read into a buffer of the announced length at once,
+	// so that a corrupt length cannot make it allocate more than the stream holds
+	payload, err := io.ReadAll(io.LimitReader(r.r, int64(h.PayloadLen+h.trailerLen())))
+	if err != nil {
+		return err
+	}
+	if len(payload) < h.PayloadLen+h.trailerLen() {
+		return fmt.Errorf("gotiny: envelope payload: %w", io.ErrUnexpectedEOF)
+	}
+	payload, trailer := payload[:h.PayloadLen:h.PayloadLen], payload[h.PayloadLen:]
+	if h.HasChecksum && !checksumValid(crc32.Update(sum, castagnoli, payload), trailer) {
+		return ErrChecksum
+	}
+	return decodePayload(r.opts, h, payload, is)
+}
+
+// peekHeader returns the header of the next envelope without consuming it, or as
+// much of it as the stream holds and the error that ended it. It peeks at no byte
+// after the header, so that it does not wait for bytes a live stream may only
+// send with the next envelope: the magic, version and flags first, then the
+// fields the flags announce, then the length one byte at a time.
+func (r *EnvelopeReader) peekHeader() ([]byte, error) {
+	n := len(envelopeMagic) + 2
+	buf, err := r.r.Peek(n)
+	if err != nil {
+		return buf, err
+	}
+	if _, err := ReadHeader(buf); !errors.Is(err, io.ErrUnexpectedEOF) {
+		return buf, nil // rejected already, let Decode report why
+	}
+	flags := buf[n-1]
+	if flags&flagFingerprint != 0 {
+		n += 8
+	}
+	if flags&flagDictionary != 0 {
+		n += 4
+	}
+	// the ninth byte of a varint is always its last
+	for end := n + 9; n < end; {
+		n++
+		if buf, err = r.r.Peek(n); err != nil || buf[n-1] < 0x80 {
+			return buf, err
+		}
+	}
+	return buf, nil
+}
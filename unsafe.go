@@ -1,3 +1,5 @@
+//go:build !gotiny_safe
+
 package gotiny
 
 import (
@@ -5,6 +7,9 @@ import (
 	"unsafe"
 )
 
+// refVal mirrors the layout of reflect.Value, to reach the data of values that
+// are not addressable without copying them. Build with the gotiny_safe tag to
+// use only the public API of reflect instead, see safe.go.
 type refVal struct {
 	_    unsafe.Pointer
 	ptr  unsafe.Pointer
@@ -30,9 +35,3 @@ func getUnsafePointer(rv reflect.Value) unsafe.Pointer {
 		return vv.ptr
 	}
 }
-
-type sliceHeader struct {
-	data unsafe.Pointer
-	len  int
-	cap  int
-}
@@ -3,6 +3,7 @@ package gotiny
 import (
 	"encoding"
 	"encoding/gob"
+	"math"
 	"reflect"
 	"strings"
 	"unsafe"
@@ -10,13 +11,16 @@ import (
 
 const ptr1Size = 4 << (^uintptr(0) >> 63)
 
-func float64ToUint64(v unsafe.Pointer) uint64 {
-	return reverse64Byte(*(*uint64)(v))
+// float64ToUint64 returns the IEEE 754 bits of v with their bytes reversed, so that
+// the sign, the exponent and the high bits of the mantissa, which most values have
+// set, become the low bits, which the varint encodes first. Like all the conversions
+// below it is defined on values, so it does not depend on the byte order of the host.
+func float64ToUint64(v float64) uint64 {
+	return reverse64Byte(math.Float64bits(v))
 }
 
 func uint64ToFloat64(u uint64) float64 {
-	u = reverse64Byte(u)
-	return *((*float64)(unsafe.Pointer(&u)))
+	return math.Float64frombits(reverse64Byte(u))
 }
 
 // reverse64Byte reverses the byte order of a 64-bit unsigned integer.
@@ -39,13 +43,22 @@ func reverse64Byte(u uint64) uint64 {
 	return u
 }
 
-func float32ToUint32(v unsafe.Pointer) uint32 {
-	return reverse32Byte(*(*uint32)(v))
+func float32ToUint32(v float32) uint32 {
+	return reverse32Byte(math.Float32bits(v))
 }
 
 func uint32ToFloat32(u uint32) float32 {
-	u = reverse32Byte(u)
-	return *((*float32)(unsafe.Pointer(&u)))
+	return math.Float32frombits(reverse32Byte(u))
+}
+
+// complex64ToUint64 returns the IEEE 754 bits of the real part of v in the low
+// 32 bits and those of the imaginary part in the high 32 bits.
+func complex64ToUint64(v complex64) uint64 {
+	return uint64(math.Float32bits(real(v))) | uint64(math.Float32bits(imag(v)))<<32
+}
+
+func uint64ToComplex64(u uint64) complex64 {
+	return complex(math.Float32frombits(uint32(u)), math.Float32frombits(uint32(u>>32)))
 }
 
 // reverse32Byte reverses the byte order of a 32-bit unsigned integer.
@@ -103,6 +116,13 @@ func uint16ToInt16(u uint16) int16 {
 	return (-(v & 1)) ^ (v>>1)&0x7FFF
 }
 
+// sliceHeader is the layout of a slice.
+type sliceHeader struct {
+	data unsafe.Pointer
+	len  int
+	cap  int
+}
+
 func isNil(p unsafe.Pointer) bool {
 	return *(*unsafe.Pointer)(p) == nil
 }
@@ -127,6 +147,49 @@ type Serializer interface {
 	GotinyDecode([]byte) int
 }
 
+// nestedSerializer is implemented, besides Serializer, by the methods cmd/gotinygen
+// generates. They encode and decode a value as part of the enclosing one, continuing
+// its byte of bools where GotinyEncode and GotinyDecode would start their own, so they
+// produce exactly the bytes of the engines built from the structure of the type.
+// The engines use them to encode and decode, and the structure for everything else.
+type nestedSerializer interface {
+	GotinyEncodeNested(buf []byte, boolPos int, boolBit byte) ([]byte, int, byte)
+	GotinyDecodeNested(buf []byte, index int, boolByte, boolBit byte) (int, byte, byte)
+}
+
+var nestedSerializerType = reflect.TypeFor[nestedSerializer]()
+
+// isNestedSerializer reports whether the pointer type of rt implements nestedSerializer.
+func isNestedSerializer(rt reflect.Type) bool {
+	return reflect.PointerTo(rt).Implements(nestedSerializerType)
+}
+
+// nestedEncEngine returns an engine that encodes values of rt, which implements
+// nestedSerializer, with the generated method, or with engine, built from the
+// structure of rt, for canonical encoders and encoders with packed floats,
+// since the generated methods encode neither canonically nor with packed floats.
+func nestedEncEngine(rt reflect.Type, engine encEng) encEng {
+	return func(e *Encoder, p unsafe.Pointer) {
+		if e.canonical || e.packedFloats {
+			engine(e, p)
+			return
+		}
+		e.buf, e.boolPos, e.boolBit = reflect.NewAt(rt, p).Interface().(nestedSerializer).GotinyEncodeNested(e.buf, e.boolPos, e.boolBit)
+	}
+}
+
+// nestedDecEngine is nestedEncEngine for decoding. The generated method is not used
+// by decoders with packed floats or saturating overflows, which it does not handle.
+func nestedDecEngine(rt reflect.Type, engine decEng) decEng {
+	return func(d *Decoder, p unsafe.Pointer) {
+		if d.packedFloats || d.overflow != OverflowError {
+			engine(d, p)
+			return
+		}
+		d.index, d.boolPos, d.boolBit = reflect.NewAt(rt, p).Interface().(nestedSerializer).GotinyDecodeNested(d.buf, d.index, d.boolPos, d.boolBit)
+	}
+}
+
 // implementOtherSerializer generates encoding and decoding engines for types that implement
 // custom serialization interfaces. It supports three interfaces: Serializer, encoding.BinaryMarshaler
 // and encoding.BinaryUnmarshaler, and gob.GobEncoder and gob.GobDecoder.
@@ -150,7 +213,11 @@ type Serializer interface {
 // and GobDecode methods.
 //
 // If the type does not implement any of these interfaces, the function returns nil for both encEng and decEng.
+// It also does for types implementing nestedSerializer, which are handled like their structure.
 func implementOtherSerializer(rt reflect.Type) (encEng encEng, decEng decEng) {
+	if isNestedSerializer(rt) {
+		return
+	}
 	rtNil := reflect.New(rt).Interface()
 	if _, ok := rtNil.(Serializer); ok {
 		encEng = func(e *Encoder, p unsafe.Pointer) {
@@ -216,22 +283,32 @@ func implementOtherSerializer(rt reflect.Type) (encEng encEng, decEng decEng) {
 // - fields: A slice of reflect.Type representing the types of the fields.
 // - offs: A slice of uintptr representing the offsets of the fields.
 func getFieldType(rt reflect.Type, baseOff uintptr) (fields []reflect.Type, offs []uintptr) {
+	fields, offs, _ = getFieldPath(rt, baseOff, "")
+	return
+}
+
+// getFieldPath works like getFieldType and additionally returns the path of each field,
+// which is its name prefixed by prefix and by the names of the structs it was flattened
+// from, separated by dots, e.g. "Address.City".
+func getFieldPath(rt reflect.Type, baseOff uintptr, prefix string) (fields []reflect.Type, offs []uintptr, paths []string) {
 	for i := 0; i < rt.NumField(); i++ {
 		field := rt.Field(i)
 		if ignoreField(field) {
 			continue
 		}
-		ft := field.Type
-		if ft.Kind() == reflect.Struct {
+		ft, path := field.Type, prefix+field.Name
+		if ft.Kind() == reflect.Struct && rawElem(ft) == nil && !isPOD(ft) {
 			if _, engine := implementOtherSerializer(ft); engine == nil {
-				fFields, fOffs := getFieldType(ft, field.Offset+baseOff)
+				fFields, fOffs, fPaths := getFieldPath(ft, field.Offset+baseOff, path+".")
 				fields = append(fields, fFields...)
 				offs = append(offs, fOffs...)
+				paths = append(paths, fPaths...)
 				continue
 			}
 		}
 		fields = append(fields, ft)
 		offs = append(offs, field.Offset+baseOff)
+		paths = append(paths, path)
 	}
 	return
 }
@@ -240,3 +317,28 @@ func ignoreField(field reflect.StructField) bool {
 	tinyTag, ok := field.Tag.Lookup("gotiny")
 	return ok && strings.TrimSpace(tinyTag) == "-"
 }
+
+// getDirectFields returns the fields of the struct type rt that are encoded,
+// without flattening nested structs.
+func getDirectFields(rt reflect.Type) (fields []reflect.StructField) {
+	for i := 0; i < rt.NumField(); i++ {
+		if field := rt.Field(i); !ignoreField(field) {
+			fields = append(fields, field)
+		}
+	}
+	return
+}
+
+// isMaskable reports whether a FieldMask can select fields inside values of rt,
+// which is the case for structs and pointers to them, unless they have their own serializer
+// or are encoded as a whole.
+func isMaskable(rt reflect.Type) bool {
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	if rt.Kind() != reflect.Struct || rawElem(rt) != nil || isPOD(rt) {
+		return false
+	}
+	enc, _ := implementOtherSerializer(rt)
+	return enc == nil
+}
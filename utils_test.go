@@ -1,6 +1,10 @@
 package gotiny
 
 import (
+	"bytes"
+	"math"
+	"math/bits"
+	"reflect"
 	"testing"
 )
 
@@ -139,3 +143,87 @@ func TestInt64ToUint64(t *testing.T) {
 		})
 	}
 }
+
+// TestFloatBits checks the encodings of floating point and complex numbers against
+// their definitions in terms of math.Float32bits and math.Float64bits, which do
+// not depend on how the host lays out numbers in memory.
+func TestFloatBits(t *testing.T) {
+	varint := func(us ...uint64) []byte {
+		e := Encoder{}
+		for _, u := range us {
+			e.encUint64(u)
+		}
+		return e.buf
+	}
+	rev32 := func(f float32) uint64 { return uint64(bits.ReverseBytes32(math.Float32bits(f))) }
+	rev64 := func(f float64) uint64 { return bits.ReverseBytes64(math.Float64bits(f)) }
+	for _, f := range []float64{0, 1, -1.5, math.Pi, math.Inf(-1), math.SmallestNonzeroFloat64, math.MaxFloat64} {
+		f32 := float32(f)
+		c64 := complex(f32, -f32/3)
+		c128 := complex(f, f/7)
+		for _, tc := range []struct {
+			v    any
+			want []byte
+		}{
+			{&f32, varint(rev32(f32))},
+			{&f, varint(rev64(f))},
+			{&c64, varint(uint64(math.Float32bits(real(c64))) | uint64(math.Float32bits(imag(c64)))<<32)},
+			{&c128, varint(math.Float64bits(real(c128)), math.Float64bits(imag(c128)))},
+			{&[]complex64{c64, c64}, append([]byte{1, 2}, bytes.Repeat(varint(uint64(math.Float32bits(real(c64)))|uint64(math.Float32bits(imag(c64)))<<32), 2)...)},
+			{&[1]complex128{c128}, varint(math.Float64bits(real(c128)), math.Float64bits(imag(c128)))},
+		} {
+			got := Marshal(tc.v)
+			if !bytes.Equal(got, tc.want) {
+				t.Errorf("%T %v: got %x, want %x", tc.v, reflect.ValueOf(tc.v).Elem(), got, tc.want)
+			}
+			if n := Size(tc.v); n != len(got) {
+				t.Errorf("%T %v: Size is %d, want %d", tc.v, reflect.ValueOf(tc.v).Elem(), n, len(got))
+			}
+			back := reflect.New(reflect.TypeOf(tc.v).Elem())
+			Unmarshal(got, back.Interface())
+			if !reflect.DeepEqual(back.Interface(), tc.v) {
+				t.Errorf("%T: decoded %v, want %v", tc.v, back.Elem(), reflect.ValueOf(tc.v).Elem())
+			}
+		}
+	}
+}
+
+func TestGetUnsafePointer(t *testing.T) {
+	type pair struct {
+		A int
+		B string
+	}
+	v := pair{1, "x"}
+	for _, rv := range []reflect.Value{reflect.ValueOf(v), reflect.ValueOf(&v).Elem(), reflect.ValueOf(any(&v))} {
+		var got pair
+		if rv.Kind() == reflect.Ptr {
+			got = **(**pair)(getUnsafePointer(rv))
+		} else {
+			got = *(*pair)(getUnsafePointer(rv))
+		}
+		if got != v {
+			t.Errorf("%v: got %v, want %v", rv.Type(), got, v)
+		}
+	}
+}
+
+func TestDecodeInterfaceInPlace(t *testing.T) {
+	type pair struct {
+		A int
+		B string
+	}
+	p := &pair{1, "x"}
+	var v any = p
+	var src any = &pair{2, "y"}
+	Unmarshal(Marshal(&src), &v)
+	if v.(*pair) != p || *p != (pair{2, "y"}) {
+		t.Fatalf("got %v, want the same pointer to {2 y}", v)
+	}
+
+	v = pair{1, "x"}
+	src = pair{3, "z"}
+	Unmarshal(Marshal(&src), &v)
+	if v != (pair{3, "z"}) {
+		t.Fatalf("got %v, want {3 z}", v)
+	}
+}
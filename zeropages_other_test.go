@@ -0,0 +1,5 @@
+//go:build !unix
+
+package gotiny
+
+func zeroPages(n int) ([]byte, func()) { return nil, nil }
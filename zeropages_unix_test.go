@@ -0,0 +1,15 @@
+//go:build unix
+
+package gotiny
+
+import "syscall"
+
+// zeroPages returns n zero bytes that take no memory, since they are never written
+// to, and a function that releases them, or nil if they cannot be mapped.
+func zeroPages(n int) ([]byte, func()) {
+	b, err := syscall.Mmap(-1, 0, n, syscall.PROT_READ, syscall.MAP_PRIVATE|syscall.MAP_ANON)
+	if err != nil {
+		return nil, nil
+	}
+	return b, func() { syscall.Munmap(b) }
+}